@@ -7,7 +7,7 @@ import (
 
 var clientSecrets map[string]string
 
-// Load client secrets from environment variables
+// Load client secrets from environment variables, and print them masked by [RedactField].
 func EnableAPISecretKeys() {
 	apiClients := Env("API_CLIENTS", []string{})
 	if len(apiClients) != 0 {
@@ -15,8 +15,9 @@ func EnableAPISecretKeys() {
 
 		clientSecrets = make(map[string]string)
 		for _, client := range apiClients {
-			clientSecrets[client] = Env(fmt.Sprintf("API_%s_SECRET", strings.TrimSpace(strings.ToUpper(client))), "")
-			fmt.Printf("│ %s: %s\r\n", client, clientSecrets[client])
+			key := fmt.Sprintf("API_%s_SECRET", strings.TrimSpace(strings.ToUpper(client)))
+			clientSecrets[client] = Env(key, "")
+			fmt.Printf("│ %s: %v\r\n", client, RedactField(key, clientSecrets[client]))
 		}
 
 		fmt.Println("└──────────────────────────────────────")
@@ -0,0 +1,90 @@
+package goutils
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Load a config struct from environment variables. See [BindEnv] for the supported struct tags.
+//
+//	type RedisConfig struct {
+//		URL      string        `env:"REDIS_URL" required:"true"`
+//		PoolSize int           `env:"REDIS_POOL_SIZE" default:"10"`
+//		Timeout  time.Duration `env:"REDIS_TIMEOUT" default:"5s"`
+//	}
+//
+//	cfg, err := goutils.LoadConfig[RedisConfig]()
+func LoadConfig[T any]() (T, error) {
+	var cfg T
+	err := BindEnv(&cfg)
+	return cfg, err
+}
+
+// Populate the struct pointed by `dest` from environment variables.
+// Each field is configured by the struct tags:
+//   - `env:"REDIS_URL"`: name of the environment variable. Use `env:"-"` to ignore a field.
+//   - `default:"..."`: value used when the variable is not set.
+//   - `required:"true"`: the variable must be set, unless a default value is given.
+//
+// Values are converted the same way as [Env], so every type supported by [ReflectStrConv] can be used as a field type.
+// A nested struct field without the `env` tag is populated recursively.
+// Every missing or unparseable variable is reported as an [*EnvError] in the returned error, no field is skipped silently.
+func BindEnv(dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("`dest` must be a non-nil pointer to a struct")
+	}
+
+	var errs []error
+	bindEnvStruct(v.Elem(), &errs)
+	return errors.Join(errs...)
+}
+
+func bindEnvStruct(v reflect.Value, errs *[]error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key, hasKey := field.Tag.Lookup("env")
+		if key == "-" {
+			continue
+		}
+		if !hasKey {
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+				bindEnvStruct(v.Field(i), errs)
+			}
+			continue
+		}
+
+		value, raw, ok, err := lookupEnvValue(key)
+		if err != nil {
+			*errs = append(*errs, &EnvError{Key: strings.ToUpper(key), Value: raw, Err: err})
+			continue
+		}
+		if !ok {
+			if value, ok = field.Tag.Lookup("default"); ok {
+				raw = value
+				setEnvSource(strings.ToUpper(key), envSource{source: EnvSourceDefault, fallback: value}, false)
+			}
+		}
+		if !ok {
+			if required, _ := strconv.ParseBool(field.Tag.Get("required")); required {
+				*errs = append(*errs, &EnvError{Key: strings.ToUpper(key), Err: ErrEnvNotSet})
+			}
+			continue
+		}
+
+		val, err := envStrConv(value, field.Type)
+		if err != nil {
+			*errs = append(*errs, &EnvError{Key: strings.ToUpper(key), Value: raw, Err: hideEnvSecret(err, value, raw)})
+			continue
+		}
+		v.Field(i).Set(val)
+	}
+}
@@ -6,6 +6,7 @@ import "context"
 type Connection interface {
 	// Connect to a database and return an error if it fails.
 	// The name is the name of the connection defined in .env file. If the name is empty, the default connection will be used.
+	// Use [EnvScope] to look up the variables of the connection, e.g. `EnvScope("REDIS", name...).Get("URL", "")`.
 	Open(name ...string) error
 
 	// Close the connection to the database.
@@ -2,6 +2,7 @@ package goutils
 
 import (
 	"errors"
+	"net/url"
 	"reflect"
 	"strconv"
 	"time"
@@ -17,6 +18,8 @@ func ReflectStrConv(val string, reflectType reflect.Type) (r interface{}, err er
 		r, err = ParseTime(val)
 	case reflect.TypeOf(time.Duration(0)):
 		r, err = time.ParseDuration(val)
+	case reflect.TypeOf(&url.URL{}):
+		r, err = url.Parse(val)
 	default:
 		switch reflectType.Kind() {
 		case reflect.String:
@@ -1,11 +1,17 @@
 package goutils
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"unicode/utf8"
 )
 
+// The fixed IV of the legacy ciphertexts.
 var bytesCrypt = []byte{35, 46, 57, 24, 85, 35, 24, 74, 87, 35, 88, 98, 66, 32, 14, 05}
 
 // Encode a string to base64 string
@@ -23,27 +29,193 @@ func Base64Decode(s string) string {
 	return string(decoded)
 }
 
-// Encrypt method is to encrypt or hide any classified text
+// Versions of the ciphertext format, the first byte of the ciphertexts of [Encrypt].
+// The legacy ciphertexts (AES-CFB with a fixed IV) have no version.
+const (
+	cryptVersionGCM   byte = 1 // AES-GCM with SECRET_CRYPT_SEED: version, nonce, sealed text
+	cryptVersionKeyID byte = 2 // AES-GCM with a derived key or a key of SECRET_CRYPT_KEYS: version, key ID length, key ID, nonce, sealed text
+)
+
+// Encrypt method is to encrypt or hide any classified text.
+// The text is encrypted by AES-GCM with a random nonce and the active key, see [EncryptAD].
 func Encrypt(text string) (string, error) {
-	block, err := aes.NewCipher([]byte(Env("SECRET_CRYPT_SEED", "")))
+	return EncryptAD(text, nil)
+}
+
+// Decrypt method is to extract back the encrypted text.
+// Legacy ciphertexts (AES-CFB) are still decrypted, unless SECRET_CRYPT_LEGACY is false.
+// They are not authenticated: a modified ciphertext is only rejected if its plaintext isn't valid UTF-8,
+// so SECRET_CRYPT_LEGACY=false is required to detect every modification, e.g. after [ReEncrypt] of the stored texts.
+func Decrypt(text string) (string, error) {
+	return DecryptAD(text, nil)
+}
+
+// Encrypt a text like [Encrypt], and authenticate the associated data with it, e.g. the ID of the record it belongs to.
+// The same associated data must be given to [DecryptAD].
+//
+// The key is SECRET_CRYPT_ACTIVE of the keyring SECRET_CRYPT_KEYS (e.g. `SECRET_CRYPT_KEYS=2023:key1,2024:key2`),
+// its ID is embedded in the ciphertext. Without a keyring, the key is SECRET_CRYPT_SEED.
+// The keys are derived by SECRET_CRYPT_KDF with the salt SECRET_CRYPT_SALT, see [CryptKDFHKDF], [CryptKDFScrypt] and [CryptKDFArgon2id].
+// They are not recorded in the ciphertext: if either is changed, the texts encrypted before can't be decrypted anymore.
+func EncryptAD(text string, ad []byte) (string, error) {
+	header, key, err := activeCryptKey()
 	if err != nil {
 		return "", err
 	}
-	plainText := []byte(text)
-	cfb := cipher.NewCFBEncrypter(block, bytesCrypt)
-	cipherText := make([]byte, len(plainText))
-	cfb.XORKeyStream(cipherText, plainText)
-	return base64.StdEncoding.EncodeToString(cipherText), nil
+	if header[0] == cryptVersionKeyID {
+		ad = append(append([]byte{}, header...), ad...) // The header is authenticated too
+	}
+	return sealGCM(key, header, text, ad)
 }
 
-// Decrypt method is to extract back the encrypted text
-func Decrypt(text string) (string, error) {
-	block, err := aes.NewCipher([]byte(Env("SECRET_CRYPT_SEED", "")))
+// Decrypt a text encrypted by [EncryptAD] with the same associated data.
+// An error is returned if the text or the associated data was modified, or its key isn't in the keyring anymore.
+func DecryptAD(text string, ad []byte) (string, error) {
+	plainText, _, err := decryptAD(text, ad)
+	return plainText, err
+}
+
+// Decrypt a text, and get the key header of the ciphertext if it was opened by AES-GCM, nil for a legacy ciphertext.
+func decryptAD(text string, ad []byte) (plainText string, header []byte, err error) {
+	cipherText, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return "", nil, err
+	}
+
+	legacy := Env("SECRET_CRYPT_LEGACY", true) && len(ad) == 0
+	if len(cipherText) == 0 || (cipherText[0] != cryptVersionGCM && cipherText[0] != cryptVersionKeyID) {
+		if legacy {
+			plainText, err = decryptLegacy(cipherText)
+			return plainText, nil, err
+		}
+		return "", nil, errors.New("unsupported ciphertext version")
+	}
+
+	plainText, header, err = openGCM(cipherText, ad)
+	if err != nil && legacy {
+		// A legacy ciphertext may start with a version byte by chance
+		if plain, cfbErr := decryptLegacy(cipherText); cfbErr == nil {
+			return plain, nil, nil
+		}
+	}
+	return plainText, header, err
+}
+
+// Decrypt a ciphertext and encrypt it again with the active key, to upgrade the legacy ciphertexts
+// and the ones of a rotated key. The ciphertexts of the active key are returned as they are.
+func ReEncrypt(text string) (string, error) {
+	return ReEncryptAD(text, nil)
+}
+
+// Decrypt a ciphertext of [EncryptAD] and encrypt it again with the active key, like [ReEncrypt], with the same associated data.
+func ReEncryptAD(text string, ad []byte) (string, error) {
+	plainText, header, err := decryptAD(text, ad)
+	if err != nil {
+		return "", err
+	}
+	active, _, err := activeCryptKey()
 	if err != nil {
 		return "", err
 	}
+	// Only a ciphertext opened by AES-GCM is kept, a legacy one may start with the same bytes
+	if header != nil && bytes.Equal(header, active) {
+		return text, nil
+	}
+	return EncryptAD(plainText, ad)
+}
 
-	cipherText, err := base64.StdEncoding.DecodeString(text)
+// Get the version and the key ID of a ciphertext, the key ID is only embedded by cryptVersionKeyID.
+func cipherHeader(cipherText []byte) (version byte, kid string) {
+	if len(cipherText) == 0 {
+		return 0, ""
+	}
+	if cipherText[0] == cryptVersionKeyID && len(cipherText) >= 2 && len(cipherText) >= 2+int(cipherText[1]) {
+		return cipherText[0], string(cipherText[2 : 2+int(cipherText[1])])
+	}
+	return cipherText[0], ""
+}
+
+// Seal a text with AES-GCM and a random nonce, the result is the base64 of the header, the nonce and the sealed text.
+func sealGCM(key []byte, header []byte, text string, ad []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := make([]byte, len(header)+gcm.NonceSize(), len(header)+gcm.NonceSize()+len(text)+gcm.Overhead())
+	copy(sealed, header)
+	nonce := sealed[len(header):]
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(sealed, nonce, []byte(text), ad)), nil
+}
+
+// Open a versioned ciphertext, with SECRET_CRYPT_SEED or the key of its key ID. The key header of the ciphertext is returned.
+func openGCM(cipherText []byte, ad []byte) (string, []byte, error) {
+	var key, header []byte
+	var err error
+	if version, kid := cipherHeader(cipherText); version == cryptVersionKeyID {
+		if len(cipherText) < 2+len(kid) {
+			return "", nil, errors.New("ciphertext too short")
+		}
+		header = cipherText[:2+len(kid)]
+		key, err = cryptKeyByID(kid)
+		ad = append(append([]byte{}, header...), ad...) // The header is authenticated too
+	} else {
+		header = cipherText[:1]
+		key, err = cryptSeed()
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", nil, err
+	}
+	cipherText = cipherText[len(header):]
+	if len(cipherText) < gcm.NonceSize()+gcm.Overhead() {
+		return "", nil, errors.New("ciphertext too short")
+	}
+	plainText, err := gcm.Open(nil, cipherText[:gcm.NonceSize()], cipherText[gcm.NonceSize():], ad)
+	if err != nil {
+		if header[0] == cryptVersionKeyID {
+			return "", nil, fmt.Errorf("%v: the text was modified, %s", err, cryptKDFChangedHint)
+		}
+		return "", nil, err
+	}
+	return string(plainText), header, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Decrypt a legacy ciphertext, and check it is plausible. A modified ciphertext (e.g. a versioned one with its version byte changed)
+// is decrypted by CFB as random bytes, which are almost never valid UTF-8 unlike the legacy texts.
+func decryptLegacy(cipherText []byte) (string, error) {
+	plainText, err := decryptCFB(cipherText)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.ValidString(plainText) {
+		return "", errors.New("invalid legacy ciphertext")
+	}
+	return plainText, nil
+}
+
+// Decrypt a legacy ciphertext, encrypted by AES-CFB with SECRET_CRYPT_SEED and the fixed IV bytesCrypt.
+func decryptCFB(cipherText []byte) (string, error) {
+	seed, err := cryptSeed()
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(seed)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,187 @@
+package goutils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDecryptVersionByteTampered(t *testing.T) {
+	t.Setenv("SECRET_CRYPT_SEED", "0123456789abcdef0123456789abcdef")
+	text, err := Encrypt("a secret long enough to be checked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherText, _ := base64.StdEncoding.DecodeString(text)
+
+	for _, version := range []byte{0, 3, 0xff} {
+		tampered := append([]byte{version}, cipherText[1:]...)
+		if plain, err := Decrypt(base64.StdEncoding.EncodeToString(tampered)); err == nil {
+			t.Fatalf("version %d: tampered ciphertext decrypted as %q", version, plain)
+		}
+	}
+
+	if plain, err := Decrypt(text); err != nil || plain != "a secret long enough to be checked" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	seed := "0123456789abcdef0123456789abcdef"
+	t.Setenv("SECRET_CRYPT_SEED", seed)
+	block, _ := aes.NewCipher([]byte(seed))
+	cipherText := make([]byte, len("legacy secret"))
+	cipher.NewCFBEncrypter(block, bytesCrypt).XORKeyStream(cipherText, []byte("legacy secret"))
+	text := base64.StdEncoding.EncodeToString(cipherText)
+
+	if plain, err := Decrypt(text); err != nil || plain != "legacy secret" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+	t.Setenv("SECRET_CRYPT_LEGACY", "false")
+	if _, err := Decrypt(text); err == nil {
+		t.Fatal("legacy ciphertext decrypted with SECRET_CRYPT_LEGACY=false")
+	}
+}
+
+func TestReEncryptLegacyWithVersionByte(t *testing.T) {
+	seed := "abcdefghijklmnop"
+	t.Setenv("SECRET_CRYPT_SEED", seed)
+	block, _ := aes.NewCipher([]byte(seed))
+	plain := "'quoted legacy secret"
+	cipherText := make([]byte, len(plain))
+	cipher.NewCFBEncrypter(block, bytesCrypt).XORKeyStream(cipherText, []byte(plain))
+	if cipherText[0] != cryptVersionGCM {
+		t.Fatalf("the legacy ciphertext starts with %d, the test needs %d", cipherText[0], cryptVersionGCM)
+	}
+	legacy := base64.StdEncoding.EncodeToString(cipherText)
+
+	text, err := ReEncrypt(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text == legacy {
+		t.Fatal("the legacy ciphertext is not encrypted again")
+	}
+	if again, err := ReEncrypt(text); err != nil || again != text {
+		t.Fatalf("ReEncrypt of an active ciphertext = %q, %v", again, err)
+	}
+	t.Setenv("SECRET_CRYPT_LEGACY", "false")
+	if got, err := Decrypt(text); err != nil || got != plain {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+}
+
+func TestReEncryptAD(t *testing.T) {
+	t.Setenv("SECRET_CRYPT_KEYS", "old:0123456789abcdef0123456789abcdef")
+	text, err := EncryptAD("secret", []byte("user:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SECRET_CRYPT_KEYS", "old:0123456789abcdef0123456789abcdef,new:fedcba9876543210fedcba9876543210")
+	t.Setenv("SECRET_CRYPT_ACTIVE", "new")
+	rotated, err := ReEncryptAD(text, []byte("user:1"))
+	if err != nil || rotated == text {
+		t.Fatalf("ReEncryptAD = %q, %v", rotated, err)
+	}
+	if _, kid := cipherHeader(mustBase64(t, rotated)); kid != "new" {
+		t.Fatalf("key ID = %q, want new", kid)
+	}
+	if plain, err := DecryptAD(rotated, []byte("user:1")); err != nil || plain != "secret" {
+		t.Fatalf("DecryptAD = %q, %v", plain, err)
+	}
+	if _, err := ReEncryptAD(text, []byte("user:2")); err == nil {
+		t.Fatal("ReEncryptAD with other associated data must fail")
+	}
+}
+
+func mustBase64(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecryptKDFSaltChanged(t *testing.T) {
+	t.Setenv("SECRET_CRYPT_SEED", "a passphrase of any length")
+	t.Setenv("SECRET_CRYPT_KDF", CryptKDFHKDF)
+	t.Setenv("SECRET_CRYPT_SALT", "salt-1")
+	text, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := Decrypt(text); err != nil || plain != "secret" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+
+	t.Setenv("SECRET_CRYPT_SALT", "salt-2")
+	if _, err := Decrypt(text); err == nil || !strings.Contains(err.Error(), "SECRET_CRYPT_SALT") {
+		t.Fatalf("Decrypt error = %v, want a hint about SECRET_CRYPT_SALT", err)
+	}
+}
+
+func TestEncryptKeyring(t *testing.T) {
+	k1, k2 := "k1:0123456789abcdef0123456789abcdef", "k2:fedcba9876543210fedcba9876543210"
+	t.Setenv("SECRET_CRYPT_KEYS", k1)
+	text, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherText := mustBase64(t, text)
+	if version, kid := cipherHeader(cipherText); version != cryptVersionKeyID || kid != "k1" {
+		t.Fatalf("header = %d %q, want %d k1", version, kid, cryptVersionKeyID)
+	}
+
+	// The key ID is authenticated with the text
+	tampered := append([]byte{}, cipherText...)
+	tampered[2] = 'x'
+	if _, err := Decrypt(base64.StdEncoding.EncodeToString(tampered)); err == nil {
+		t.Fatal("ciphertext with a tampered key ID decrypted")
+	}
+
+	t.Setenv("SECRET_CRYPT_KEYS", k1+","+k2)
+	t.Setenv("SECRET_CRYPT_ACTIVE", "k2")
+	if plain, err := Decrypt(text); err != nil || plain != "secret" {
+		t.Fatalf("Decrypt with a rotated key = %q, %v", plain, err)
+	}
+	rotated, err := ReEncrypt(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, kid := cipherHeader(mustBase64(t, rotated)); kid != "k2" {
+		t.Fatalf("ReEncrypt key ID = %q, want k2", kid)
+	}
+
+	t.Setenv("SECRET_CRYPT_KEYS", k2)
+	t.Setenv("SECRET_CRYPT_ACTIVE", "")
+	if _, err := Decrypt(text); err == nil || !strings.Contains(err.Error(), `unknown key ID "k1"`) {
+		t.Fatalf("Decrypt with a removed key: %v", err)
+	}
+	if plain, err := Decrypt(rotated); err != nil || plain != "secret" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+}
+
+func TestCryptKeyringErrors(t *testing.T) {
+	for _, tc := range []struct {
+		keys, active, err string
+	}{
+		{keys: "k1", err: "SECRET_CRYPT_KEYS: invalid entry"},
+		{keys: "k1:", err: "SECRET_CRYPT_KEYS: invalid entry"},
+		{keys: "k1:0123456789abcdef,k2:fedcba9876543210", err: "SECRET_CRYPT_ACTIVE is not set"},
+		{keys: "k1:0123456789abcdef", active: "k2", err: `SECRET_CRYPT_ACTIVE: key ID "k2" is not in SECRET_CRYPT_KEYS`},
+		{keys: "k1:short", err: `SECRET_CRYPT_KEYS (key ID "k1")`},
+	} {
+		t.Run(tc.keys, func(t *testing.T) {
+			t.Setenv("SECRET_CRYPT_KEYS", tc.keys)
+			t.Setenv("SECRET_CRYPT_ACTIVE", tc.active)
+			if err := ValidateCryptKeys(); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("ValidateCryptKeys = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package goutils
+
+import (
+	"crypto/aes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The key derivation functions selected by SECRET_CRYPT_KDF.
+// The KDF and SECRET_CRYPT_SALT are global settings, not recorded in the ciphertexts:
+// changing either of them changes every derived key, so the texts must be decrypted and encrypted again before.
+const (
+	CryptKDFNone     = "none"     // The keys are used as they are, they must be 16, 24 or 32 bytes
+	CryptKDFHKDF     = "hkdf"     // HKDF-SHA256, for random keys of any length
+	CryptKDFScrypt   = "scrypt"   // scrypt (N=32768, r=8, p=1), for passphrases
+	CryptKDFArgon2id = "argon2id" // Argon2id (t=1, m=64MB, p=4), for passphrases
+)
+
+// Check the encryption keys can be used, it is called by [QuickLoad].
+// The error names the misconfigured variable: SECRET_CRYPT_SEED, SECRET_CRYPT_KEYS, SECRET_CRYPT_ACTIVE, SECRET_CRYPT_KDF or SECRET_CRYPT_SALT.
+func ValidateCryptKeys() error {
+	if _, err := cryptKDF(); err != nil {
+		return err
+	}
+	keys, _, err := cryptKeyring()
+	if err != nil {
+		return err
+	}
+	for kid := range keys {
+		if _, err := cryptKeyByID(kid); err != nil {
+			return err
+		}
+	}
+	if Env("SECRET_CRYPT_SEED", "") != "" {
+		if _, err := cryptKeyByID(""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the header of the new ciphertexts and the key to encrypt them:
+//   - cryptVersionKeyID with the key SECRET_CRYPT_ACTIVE of the keyring SECRET_CRYPT_KEYS
+//   - cryptVersionKeyID with an empty key ID, if there is no keyring and SECRET_CRYPT_KDF is set: the key is derived from SECRET_CRYPT_SEED
+//   - cryptVersionGCM otherwise: the key is SECRET_CRYPT_SEED
+func activeCryptKey() (header []byte, key []byte, err error) {
+	_, active, err := cryptKeyring()
+	if err != nil {
+		return nil, nil, err
+	}
+	kdf, err := cryptKDF()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if active == "" && kdf == CryptKDFNone {
+		key, err = cryptSeed()
+		return []byte{cryptVersionGCM}, key, err
+	}
+	key, err = cryptKeyByID(active)
+	return append([]byte{cryptVersionKeyID, byte(len(active))}, active...), key, err
+}
+
+// Get the key of a key ID, derived by SECRET_CRYPT_KDF. The empty key ID is SECRET_CRYPT_SEED.
+func cryptKeyByID(kid string) ([]byte, error) {
+	name, material := "SECRET_CRYPT_SEED", Env("SECRET_CRYPT_SEED", "")
+	if kid != "" {
+		keys, _, err := cryptKeyring()
+		if err != nil {
+			return nil, err
+		}
+		if material = keys[kid]; material == "" {
+			return nil, fmt.Errorf("unknown key ID %q", kid)
+		}
+		name = fmt.Sprintf("SECRET_CRYPT_KEYS (key ID %q)", kid)
+	} else if material == "" {
+		return nil, errors.New("SECRET_CRYPT_SEED is not set")
+	}
+
+	kdf, err := cryptKDF()
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveCryptKey(kdf, material, kid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return key, nil
+}
+
+// Get SECRET_CRYPT_SEED as it is, the key of cryptVersionGCM and the legacy ciphertexts.
+func cryptSeed() ([]byte, error) {
+	seed := Env("SECRET_CRYPT_SEED", "")
+	if seed == "" {
+		return nil, errors.New("SECRET_CRYPT_SEED is not set")
+	}
+	if err := checkAESKey([]byte(seed)); err != nil {
+		return nil, fmt.Errorf("SECRET_CRYPT_SEED: %v", err)
+	}
+	return []byte(seed), nil
+}
+
+// Get the keyring SECRET_CRYPT_KEYS (a list of `id:key`) and its active key ID SECRET_CRYPT_ACTIVE,
+// which is optional if there is a single key. The active ID is empty if there is no keyring.
+func cryptKeyring() (keys map[string]string, active string, err error) {
+	entries := Env("SECRET_CRYPT_KEYS", []string{})
+	if len(entries) == 0 {
+		return nil, "", nil
+	}
+
+	keys = make(map[string]string, len(entries))
+	for _, entry := range entries {
+		kid, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || kid == "" || len(kid) > 255 || key == "" {
+			return nil, "", errors.New("SECRET_CRYPT_KEYS: invalid entry, it must be `id:key`")
+		}
+		keys[kid] = key
+		if len(entries) == 1 {
+			active = kid
+		}
+	}
+
+	if id := Env("SECRET_CRYPT_ACTIVE", ""); id != "" {
+		active = id
+	}
+	if active == "" {
+		return nil, "", errors.New("SECRET_CRYPT_ACTIVE is not set")
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, "", fmt.Errorf("SECRET_CRYPT_ACTIVE: key ID %q is not in SECRET_CRYPT_KEYS", active)
+	}
+	return keys, active, nil
+}
+
+// Get SECRET_CRYPT_KDF, default is CryptKDFNone.
+func cryptKDF() (string, error) {
+	switch kdf := strings.ToLower(Env("SECRET_CRYPT_KDF", CryptKDFNone)); kdf {
+	case CryptKDFNone, CryptKDFHKDF, CryptKDFScrypt, CryptKDFArgon2id:
+		return kdf, nil
+	default:
+		return "", fmt.Errorf("SECRET_CRYPT_KDF: unknown key derivation function %q, it must be none, hkdf, scrypt or argon2id", kdf)
+	}
+}
+
+// The hint of the authentication errors of the derived keys, the KDF settings may have been changed since the encryption.
+const cryptKDFChangedHint = "or SECRET_CRYPT_KDF or SECRET_CRYPT_SALT was changed since it was encrypted"
+
+// The derived keys, scrypt and Argon2id are too slow to run for every encryption.
+var derivedCryptKeys sync.Map
+
+// Derive a 32-byte key from a key material with the salt SECRET_CRYPT_SALT, which is required by scrypt and Argon2id.
+// The key ID is bound to the key, so the same material gives different keys for different IDs.
+func deriveCryptKey(kdf string, material string, kid string) ([]byte, error) {
+	if kdf == CryptKDFNone {
+		return []byte(material), checkAESKey([]byte(material))
+	}
+
+	salt := Env("SECRET_CRYPT_SALT", "")
+	cacheKey := strings.Join([]string{kdf, salt, kid, material}, "\x00")
+	if key, ok := derivedCryptKeys.Load(cacheKey); ok {
+		return key.([]byte), nil
+	}
+	if salt == "" && kdf != CryptKDFHKDF {
+		return nil, fmt.Errorf("SECRET_CRYPT_SALT is required by %s", kdf)
+	}
+
+	var key []byte
+	switch kdf {
+	case CryptKDFHKDF:
+		key = make([]byte, 32)
+		if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(material), []byte(salt), []byte("goutils/crypt/"+kid)), key); err != nil {
+			return nil, err
+		}
+	case CryptKDFScrypt:
+		var err error
+		if key, err = scrypt.Key([]byte(material), []byte(salt+kid), 32768, 8, 1, 32); err != nil {
+			return nil, err
+		}
+	case CryptKDFArgon2id:
+		key = argon2.IDKey([]byte(material), []byte(salt+kid), 1, 64*1024, 4, 32)
+	}
+	derivedCryptKeys.Store(cacheKey, key)
+	return key, nil
+}
+
+// Check a key has a valid AES key size.
+func checkAESKey(key []byte) error {
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("%v, it must be 16, 24 or 32 bytes, or set SECRET_CRYPT_KDF to derive a key from it", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package goutils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCryptKDF(t *testing.T) {
+	for _, kdf := range []string{CryptKDFHKDF, CryptKDFScrypt, CryptKDFArgon2id} {
+		t.Run(kdf, func(t *testing.T) {
+			t.Setenv("SECRET_CRYPT_SEED", "a passphrase of any length")
+			t.Setenv("SECRET_CRYPT_KDF", kdf)
+			t.Setenv("SECRET_CRYPT_SALT", "salt")
+			if err := ValidateCryptKeys(); err != nil {
+				t.Fatal(err)
+			}
+
+			text, err := Encrypt("secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The derived key of SECRET_CRYPT_SEED has an empty key ID
+			if version, kid := cipherHeader(mustBase64(t, text)); version != cryptVersionKeyID || kid != "" {
+				t.Fatalf("header = %d %q, want %d and an empty key ID", version, kid, cryptVersionKeyID)
+			}
+			if plain, err := Decrypt(text); err != nil || plain != "secret" {
+				t.Fatalf("Decrypt = %q, %v", plain, err)
+			}
+		})
+	}
+}
+
+func TestDeriveCryptKey(t *testing.T) {
+	t.Setenv("SECRET_CRYPT_SALT", "salt")
+	k1, err := deriveCryptKey(CryptKDFHKDF, "material", "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := deriveCryptKey(CryptKDFHKDF, "material", "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k1) != 32 || bytes.Equal(k1, k2) {
+		t.Fatal("the key ID must be bound to the derived key")
+	}
+
+	t.Setenv("SECRET_CRYPT_SALT", "other salt")
+	if k, _ := deriveCryptKey(CryptKDFHKDF, "material", "k1"); bytes.Equal(k, k1) {
+		t.Fatal("the salt must be bound to the derived key")
+	}
+}
+
+func TestValidateCryptKeysErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		env  map[string]string
+		err  string
+	}{
+		{name: "seed size", env: map[string]string{"SECRET_CRYPT_SEED": "short"}, err: "SECRET_CRYPT_SEED: crypto/aes: invalid key size"},
+		{name: "unknown kdf", env: map[string]string{"SECRET_CRYPT_SEED": "short", "SECRET_CRYPT_KDF": "md5"}, err: "SECRET_CRYPT_KDF: unknown key derivation function"},
+		{name: "scrypt salt", env: map[string]string{"SECRET_CRYPT_SEED": "short", "SECRET_CRYPT_KDF": CryptKDFScrypt}, err: "SECRET_CRYPT_SALT is required by scrypt"},
+		{name: "argon2id salt", env: map[string]string{"SECRET_CRYPT_KEYS": "k1:short", "SECRET_CRYPT_KDF": CryptKDFArgon2id}, err: "SECRET_CRYPT_SALT is required by argon2id"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, val := range tc.env {
+				t.Setenv(key, val)
+			}
+			if err := ValidateCryptKeys(); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("ValidateCryptKeys = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package goutils
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Version of the stream format of [NewEncryptWriter], followed by the header of the key (see [activeCryptKey]),
+// a random salt and the chunks. Every chunk is sealed by AES-GCM with a key derived from the salt,
+// its nonce is the chunk counter and a flag marking the last chunk, so reordered or truncated streams are detected.
+const cryptVersionStream byte = 3
+
+const (
+	cryptStreamChunkSize = 64 * 1024 // Plaintext bytes per chunk
+	cryptStreamSaltSize  = 16
+)
+
+// Create a writer encrypting everything written to `w`, with the same keys as [Encrypt].
+// The output is binary, in chunks of 64KB, so payloads of any size can be encrypted without loading them in memory.
+// Close must be called to write the last chunk, it doesn't close `w`.
+//
+//	ew, err := goutils.NewEncryptWriter(file)
+//	io.Copy(ew, backup)
+//	ew.Close()
+func NewEncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	keyHeader, key, err := activeCryptKey()
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{cryptVersionStream}, keyHeader...)
+	salt := make([]byte, cryptStreamSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	header = append(header, salt...)
+
+	aead, err := newStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, header: header, buf: make([]byte, 0, cryptStreamChunkSize)}, nil
+}
+
+// Create a reader decrypting a stream written by [NewEncryptWriter].
+// An error is returned by Read if the stream was modified or truncated, the data read before comes from authenticated chunks.
+func NewDecryptReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, cryptStreamChunkSize+64)
+	version, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if version != cryptVersionStream {
+		return nil, errors.New("unsupported stream version")
+	}
+
+	// The key header: cryptVersionGCM for SECRET_CRYPT_SEED, or cryptVersionKeyID with a key ID
+	header := []byte{version}
+	var key []byte
+	keyVersion, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	header = append(header, keyVersion)
+	switch keyVersion {
+	case cryptVersionGCM:
+		key, err = cryptSeed()
+	case cryptVersionKeyID:
+		var kidLen byte
+		if kidLen, err = br.ReadByte(); err != nil {
+			return nil, err
+		}
+		kid := make([]byte, kidLen)
+		if _, err := io.ReadFull(br, kid); err != nil {
+			return nil, err
+		}
+		header = append(append(header, kidLen), kid...)
+		key, err = cryptKeyByID(string(kid))
+	default:
+		return nil, errors.New("unsupported stream key version")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, cryptStreamSaltSize)
+	if _, err := io.ReadFull(br, salt); err != nil {
+		return nil, err
+	}
+	header = append(header, salt...)
+
+	aead, err := newStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: br, aead: aead, header: header, chunk: make([]byte, cryptStreamChunkSize+aead.Overhead())}, nil
+}
+
+// Derive the key of a stream from the encryption key and the salt of the stream.
+func newStreamAEAD(key []byte, salt []byte) (cipher.AEAD, error) {
+	streamKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte("goutils/crypt/stream")), streamKey); err != nil {
+		return nil, err
+	}
+	return newGCM(streamKey)
+}
+
+// The nonce of a chunk: the big-endian counter, then 1 for the last chunk or 0.
+func streamNonce(nonce []byte, counter uint64, last bool) []byte {
+	for i := range nonce {
+		nonce[i] = 0
+	}
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte // Authenticated with every chunk
+	buf     []byte // The plaintext of the next chunk
+	counter uint64
+	nonce   [12]byte
+	err     error
+}
+
+func (ew *encryptWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		// A full chunk is sealed when more data comes, so the last chunk is only known on Close
+		if len(ew.buf) == cryptStreamChunkSize {
+			if ew.err = ew.seal(false); ew.err != nil {
+				return n, ew.err
+			}
+		}
+		k := copy(ew.buf[len(ew.buf):cryptStreamChunkSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+// Write the last chunk, the stream can't be written anymore.
+func (ew *encryptWriter) Close() error {
+	if ew.err != nil {
+		if ew.err == errStreamClosed {
+			return nil
+		}
+		return ew.err
+	}
+	if ew.err = ew.seal(true); ew.err != nil {
+		return ew.err
+	}
+	ew.err = errStreamClosed
+	return nil
+}
+
+var errStreamClosed = errors.New("write to a closed encrypt writer")
+
+func (ew *encryptWriter) seal(last bool) error {
+	if ew.counter == 1<<64-1 {
+		return errors.New("stream too large")
+	}
+	sealed := ew.aead.Seal(nil, streamNonce(ew.nonce[:], ew.counter, last), ew.buf, ew.header)
+	ew.counter++
+	ew.buf = ew.buf[:0]
+	_, err := ew.w.Write(sealed)
+	return err
+}
+
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	chunk   []byte // The buffer of a sealed chunk
+	plain   []byte // The decrypted bytes not read yet
+	counter uint64
+	nonce   [12]byte
+	done    bool
+	err     error
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.plain) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		if dr.done {
+			return 0, io.EOF
+		}
+		dr.err = dr.open()
+	}
+
+	n := copy(p, dr.plain)
+	dr.plain = dr.plain[n:]
+	return n, nil
+}
+
+// Read and open the next chunk. It is the last one if the stream ends after it.
+func (dr *decryptReader) open() error {
+	n, err := io.ReadFull(dr.r, dr.chunk)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, err := dr.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	plain, err := dr.aead.Open(dr.chunk[:0], streamNonce(dr.nonce[:], dr.counter, last), dr.chunk[:n], dr.header)
+	if err != nil {
+		if dr.counter == 0 && dr.header[1] == cryptVersionKeyID {
+			return errors.New("stream modified, " + cryptKDFChangedHint)
+		}
+		if last {
+			return errors.New("stream truncated or modified")
+		}
+		return errors.New("stream modified")
+	}
+	dr.counter++
+	dr.plain = plain
+	dr.done = last
+	return nil
+}
@@ -0,0 +1,111 @@
+package goutils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func encryptStream(t *testing.T, plain []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	ew, err := NewEncryptWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ew.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptStream(stream []byte) ([]byte, error) {
+	dr, err := NewDecryptReader(bytes.NewReader(stream))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(dr)
+}
+
+func TestEncryptStreamRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "seed", env: map[string]string{"SECRET_CRYPT_SEED": "0123456789abcdef0123456789abcdef"}},
+		{name: "keyring", env: map[string]string{"SECRET_CRYPT_KEYS": "k1:0123456789abcdef0123456789abcdef"}},
+		{name: "kdf", env: map[string]string{"SECRET_CRYPT_SEED": "a passphrase", "SECRET_CRYPT_KDF": CryptKDFHKDF}},
+	} {
+		for _, size := range []int{0, 1, cryptStreamChunkSize - 1, cryptStreamChunkSize, cryptStreamChunkSize + 1, 3*cryptStreamChunkSize + 5} {
+			t.Run(fmt.Sprintf("%s/%d", tc.name, size), func(t *testing.T) {
+				for key, val := range tc.env {
+					t.Setenv(key, val)
+				}
+				plain := make([]byte, size)
+				rand.Read(plain)
+
+				stream := encryptStream(t, plain)
+				got, err := decryptStream(stream)
+				if err != nil {
+					t.Fatalf("size %d: %v", size, err)
+				}
+				if !bytes.Equal(got, plain) {
+					t.Fatalf("size %d: decrypted %d bytes, they differ from the plaintext", size, len(got))
+				}
+			})
+		}
+	}
+}
+
+func TestEncryptStreamFormat(t *testing.T) {
+	t.Setenv("SECRET_CRYPT_SEED", "0123456789abcdef0123456789abcdef")
+	plain := make([]byte, 2*cryptStreamChunkSize)
+	rand.Read(plain)
+	stream := encryptStream(t, plain)
+
+	// Without a keyring nor a KDF, the key header is cryptVersionGCM: the key is SECRET_CRYPT_SEED
+	if stream[0] != cryptVersionStream || stream[1] != cryptVersionGCM {
+		t.Fatalf("header = %v, want [%d %d]", stream[:2], cryptVersionStream, cryptVersionGCM)
+	}
+	headerLen := 2 + cryptStreamSaltSize
+	sealedChunk := cryptStreamChunkSize + 16
+	if len(stream) != headerLen+2*sealedChunk {
+		t.Fatalf("stream length = %d, want %d", len(stream), headerLen+2*sealedChunk)
+	}
+	first, second := stream[headerLen:headerLen+sealedChunk], stream[headerLen+sealedChunk:]
+	if got, err := decryptStream(stream); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("the version 1 stream isn't decrypted with SECRET_CRYPT_SEED: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		stream []byte
+	}{
+		{name: "truncated at a chunk boundary", stream: stream[:headerLen+sealedChunk]},
+		{name: "truncated in a chunk", stream: stream[:len(stream)-1]},
+		{name: "header only", stream: stream[:headerLen]},
+		{name: "empty", stream: nil},
+		{name: "tampered salt", stream: flipByte(stream, 2)},
+		{name: "tampered key version", stream: flipByte(stream, 1)},
+		{name: "tampered version", stream: flipByte(stream, 0)},
+		{name: "tampered chunk", stream: flipByte(stream, headerLen+sealedChunk+1)},
+		{name: "reordered chunks", stream: append(append(append([]byte{}, stream[:headerLen]...), second...), first...)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := decryptStream(tc.stream); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func flipByte(b []byte, i int) []byte {
+	b = append([]byte{}, b...)
+	b[i] ^= 0x80
+	return b
+}
@@ -0,0 +1,318 @@
+package goutils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A value read from a dotenv file, before variable expansion.
+type dotenvValue struct {
+	raw   string
+	quote byte // 0 for unquoted values, '\'' or '"' for quoted values
+}
+
+// Read a dotenv file. A missing file is not an error, it returns an empty map.
+func readDotenv(filename string) (map[string]dotenvValue, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]dotenvValue{}, nil
+		}
+		return nil, err
+	}
+
+	vars, err := parseDotenv(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return vars, nil
+}
+
+// Parse the content of a dotenv file. The syntax is the same as [godotenv](https://github.com/joho/godotenv):
+// `KEY=value`, `KEY: value`, an optional `export` prefix, `#` comments, single and double quoted (multiline) values.
+// Variables are not expanded here, see [expandDotenv].
+func parseDotenv(src []byte) (map[string]dotenvValue, error) {
+	vars := make(map[string]dotenvValue)
+	src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	for line := 1; len(src) > 0; line++ {
+		var stmt []byte
+		stmt, src, _ = bytes.Cut(src, []byte("\n"))
+		stmt = bytes.TrimSpace(stmt)
+		if len(stmt) == 0 || stmt[0] == '#' {
+			continue
+		}
+
+		if rest, ok := bytes.CutPrefix(stmt, []byte("export")); ok && len(rest) > 0 && unicode.IsSpace(rune(rest[0])) {
+			stmt = bytes.TrimSpace(rest)
+		}
+
+		sep := bytes.IndexAny(stmt, "=:")
+		if sep <= 0 {
+			return nil, fmt.Errorf("line %d: missing '=' after variable name", line)
+		}
+		key := string(bytes.TrimSpace(stmt[:sep]))
+		for _, c := range key {
+			if !unicode.IsLetter(c) && !unicode.IsNumber(c) && c != '_' && c != '.' {
+				return nil, fmt.Errorf("line %d: unexpected character %q in variable name %q", line, c, key)
+			}
+		}
+
+		value := bytes.TrimSpace(stmt[sep+1:])
+		if len(value) == 0 || (value[0] != '"' && value[0] != '\'') {
+			// Unquoted value, strip the inline comment
+			if i := bytes.Index(value, []byte(" #")); i >= 0 {
+				value = bytes.TrimSpace(value[:i])
+			}
+			vars[key] = dotenvValue{raw: string(value)}
+			continue
+		}
+
+		// Quoted value, it may span multiple lines
+		quote := value[0]
+		value = append(append([]byte{}, value[1:]...), '\n')
+		value = append(value, src...)
+		end := -1
+		for i := 0; i < len(value); i++ {
+			if value[i] == '\\' && quote == '"' {
+				i++
+			} else if value[i] == quote {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unterminated quoted value of %s", line, key)
+		}
+
+		raw := string(value[:end])
+		consumed := strings.Count(raw, "\n")
+		line += consumed
+		// Drop the lines consumed by a multiline value, the rest of the closing line is a comment
+		for i := 0; i < consumed; i++ {
+			_, src, _ = bytes.Cut(src, []byte("\n"))
+		}
+		vars[key] = dotenvValue{raw: strings.TrimSuffix(raw, "\n"), quote: quote}
+	}
+	return vars, nil
+}
+
+// Expand variables in the merged values of dotenv files.
+// The following forms are supported in unquoted and double quoted values, single quoted values are kept as they are:
+//   - `${VAR}`: value of VAR, or empty if VAR is not set
+//   - `$VAR`: the same for upper case names (`[A-Z_][A-Z0-9_]*`), other `$` are kept as they are, e.g. `pa$$word`
+//   - `${VAR:-default}`: `default` if VAR is not set or empty
+//   - `${VAR-default}`: `default` if VAR is not set
+//   - `${VAR:?message}`: error with `message` if VAR is not set or empty
+//   - `${VAR?message}`: error with `message` if VAR is not set
+//
+// A variable is resolved from the process environment by `processEnv` first, then from `vars`,
+// regardless of the file it is defined in. Cyclic references are reported as errors.
+func expandDotenv(vars map[string]dotenvValue, processEnv func(string) (string, bool)) (map[string]string, error) {
+	e := &dotenvExpander{
+		env:      processEnv,
+		vars:     vars,
+		resolved: make(map[string]string),
+		failed:   make(map[string]error),
+	}
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		if _, err := e.resolve(key); err != nil && !e.reported(err) {
+			errs = append(errs, err)
+		}
+	}
+	return e.resolved, errors.Join(errs...)
+}
+
+type dotenvExpander struct {
+	env      func(string) (string, bool)
+	vars     map[string]dotenvValue
+	resolved map[string]string
+	failed   map[string]error
+	stack    []string // Keys being resolved, to detect cycles
+	errs     []error  // Errors already returned by expandDotenv
+}
+
+// Check an error has been reported already, so a failure is reported once for all keys referencing it.
+func (e *dotenvExpander) reported(err error) bool {
+	for _, r := range e.errs {
+		if r == err {
+			return true
+		}
+	}
+	e.errs = append(e.errs, err)
+	return false
+}
+
+// Resolve the final value of a key defined in dotenv files.
+func (e *dotenvExpander) resolve(key string) (string, error) {
+	if val, ok := e.resolved[key]; ok {
+		return val, nil
+	}
+	if err, ok := e.failed[key]; ok {
+		return "", err
+	}
+	for i, k := range e.stack {
+		if k == key {
+			return "", fmt.Errorf("env %s: variable cycle %s", key, strings.Join(append(e.stack[i:], key), " -> "))
+		}
+	}
+
+	e.stack = append(e.stack, key)
+	val, err := e.expand(e.vars[key])
+	e.stack = e.stack[:len(e.stack)-1]
+	if err != nil {
+		e.failed[key] = err
+		return "", err
+	}
+	e.resolved[key] = val
+	return val, nil
+}
+
+// Look up a referenced variable in the process environment, then in dotenv files.
+func (e *dotenvExpander) lookup(key string) (string, bool, error) {
+	if val, ok := e.env(key); ok {
+		return val, true, nil
+	}
+	if _, ok := e.vars[key]; !ok {
+		return "", false, nil
+	}
+	val, err := e.resolve(key)
+	return val, err == nil, err
+}
+
+func (e *dotenvExpander) expand(v dotenvValue) (string, error) {
+	if v.quote == '\'' {
+		return v.raw, nil
+	}
+	return e.expandStr(v.raw, v.quote == '"')
+}
+
+func (e *dotenvExpander) expandStr(s string, escapes bool) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			next := s[i+1]
+			switch {
+			case next == '$':
+				sb.WriteByte('$')
+			case escapes && next == 'n':
+				sb.WriteByte('\n')
+			case escapes && next == 'r':
+				sb.WriteByte('\r')
+			case escapes && next == 't':
+				sb.WriteByte('\t')
+			case escapes && (next == '"' || next == '\\'):
+				sb.WriteByte(next)
+			default:
+				sb.WriteByte(c)
+				continue
+			}
+			i++
+			continue
+		}
+		if c != '$' || i+1 >= len(s) {
+			sb.WriteByte(c)
+			continue
+		}
+
+		// $VAR, only upper case names like godotenv, so `pa$$word` or `$price` are kept as they are
+		if s[i+1] != '{' {
+			end := i + 1
+			for end < len(s) && (s[end] == '_' || s[end] >= 'A' && s[end] <= 'Z' || end > i+1 && s[end] >= '0' && s[end] <= '9') {
+				end++
+			}
+			if end == i+1 {
+				sb.WriteByte(c)
+				continue
+			}
+			val, _, err := e.lookup(s[i+1 : end])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(val)
+			i = end - 1
+			continue
+		}
+
+		// ${VAR...}, find the matching brace
+		depth, end := 0, -1
+		for j := i + 1; j < len(s) && end < 0; j++ {
+			switch s[j] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					end = j
+				}
+			}
+		}
+		if end < 0 {
+			return "", fmt.Errorf("env %s: unterminated variable reference %q", e.stack[len(e.stack)-1], s[i:])
+		}
+		val, err := e.expandRef(s[i+2 : end])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(val)
+		i = end
+	}
+	return sb.String(), nil
+}
+
+// Expand the content of `${...}`.
+func (e *dotenvExpander) expandRef(ref string) (string, error) {
+	name := ref
+	for i := 0; i < len(ref); i++ {
+		if !isEnvNameChar(ref[i]) {
+			name = ref[:i]
+			break
+		}
+	}
+	op := ref[len(name):]
+
+	val, ok, err := e.lookup(name)
+	if err != nil {
+		return "", err
+	}
+
+	// `:` treats an empty value as not set
+	if strings.HasPrefix(op, ":") {
+		ok = ok && val != ""
+		op = op[1:]
+	}
+	switch {
+	case op != "" && op[0] != '-' && op[0] != '?':
+		return "", fmt.Errorf("env %s: invalid variable reference ${%s}", e.stack[len(e.stack)-1], ref)
+	case op == "" || ok:
+		return val, nil
+	case op[0] == '-':
+		return e.expandStr(op[1:], false)
+	}
+
+	msg, err := e.expandStr(op[1:], false)
+	if err != nil {
+		return "", err
+	}
+	if msg == "" {
+		msg = "variable is not set"
+	}
+	return "", fmt.Errorf("env %s: %s: %s", e.stack[len(e.stack)-1], name, msg)
+}
+
+func isEnvNameChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
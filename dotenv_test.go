@@ -0,0 +1,68 @@
+package goutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDotenv(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		env  map[string]string // The process environment
+		want map[string]string
+		err  string // A part of the expected error
+	}{
+		{name: "unquoted", src: "A=1\nB: 2\n\n# comment\nC = 3 ", want: map[string]string{"A": "1", "B": "2", "C": "3"}},
+		{name: "export", src: "export A=1\nexportB=2", want: map[string]string{"A": "1", "exportB": "2"}},
+		{name: "inline comment", src: "A=1 # comment\nB=a#b\nC='x' # comment\nD=\"y\" # comment", want: map[string]string{"A": "1", "B": "a#b", "C": "x", "D": "y"}},
+		{name: "single quoted", src: `A='$B \n "x"'` + "\nB=1", want: map[string]string{"A": `$B \n "x"`, "B": "1"}},
+		{name: "double quoted", src: `A="a\tb\n\"c\" \\ \$B"` + "\nB=1", want: map[string]string{"A": "a\tb\n\"c\" \\ $B", "B": "1"}},
+		{name: "multiline", src: "A=\"line1\nline2\"\nB='x\ny'\nC=3", want: map[string]string{"A": "line1\nline2", "B": "x\ny", "C": "3"}},
+		{name: "unterminated", src: "A=\"line1\nline2", err: "line 1: unterminated quoted value of A"},
+		{name: "invalid name", src: "A-B=1", err: `unexpected character '-'`},
+		{name: "missing separator", src: "A", err: "line 1: missing '='"},
+		{name: "reference", src: "A=$B-${B}\nB=1", want: map[string]string{"A": "1-1", "B": "1"}},
+		{name: "process env first", src: "A=$B\nB=1", env: map[string]string{"B": "2"}, want: map[string]string{"A": "2", "B": "1"}},
+		{name: "undefined", src: "A=x${B}y$C", want: map[string]string{"A": "xy"}},
+		{name: "dollars kept", src: "PW=pa$$word\nB=$price $1 $\nC=\"a$b\"", want: map[string]string{"PW": "pa$$word", "B": "$price $1 $", "C": "a$b"}},
+		{name: "default", src: "A=${B:-x}\nB=\nC=${B-y}\nD=${E:-$B}", want: map[string]string{"A": "x", "B": "", "C": "", "D": ""}},
+		{name: "required", src: "A=${B:?B is required}", err: "env A: B: B is required"},
+		{name: "required empty", src: "A=${B?}\nB=\nC=${B:?}", err: "env C: B: variable is not set"},
+		{name: "cycle", src: "A=$B\nB=${C}\nC=$A", err: "variable cycle A -> B -> C -> A"},
+		{name: "unterminated reference", src: "A=${B", err: `unterminated variable reference "${B"`},
+		{name: "invalid reference", src: "A=${B+x}", err: "invalid variable reference ${B+x}"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := loadDotenvSrc(tc.src, tc.env)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("error = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+			for key, want := range tc.want {
+				if got[key] != want {
+					t.Errorf("%s = %q, want %q", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func loadDotenvSrc(src string, env map[string]string) (map[string]string, error) {
+	vars, err := parseDotenv([]byte(src))
+	if err != nil {
+		return nil, err
+	}
+	return expandDotenv(vars, func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	})
+}
@@ -13,21 +13,70 @@
 package goutils
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/goccy/go-json"
 )
 
-var (
-	env = flag.String("env", "", "Environment profile") // Read environment profile from command line
-)
+var envFlag *string // The `-env` command line flag, registered by RegisterEnvFlag
+
+// Register the `-env` command line flag to select the environment profile loaded by [LoadEnv].
+// The flag is registered on flag.CommandLine if no FlagSet is given, and it must be called before the flags are parsed.
+// Applications using another flag library (e.g. cobra/pflag) should pass the profile to [LoadEnvProfile] instead.
+func RegisterEnvFlag(fs ...*flag.FlagSet) {
+	flags := flag.CommandLine
+	if len(fs) > 0 {
+		flags = fs[0]
+	}
+	envFlag = flags.String("env", "", "Environment profile")
+}
+
+// Get the environment profile to load: the `-env` flag if it is registered and set,
+// then the APP_ENV environment variable, default is "development".
+func EnvProfile() string {
+	if envFlag != nil && *envFlag != "" {
+		return *envFlag
+	}
+	return Env("APP_ENV", "development")
+}
 
-// Load environment variables from .env file.
+// Options of [LoadEnvProfile].
+type EnvOption func(*envOptions)
+
+type envOptions struct {
+	dir   string
+	watch time.Duration
+}
+
+// Load the dotenv files from `dir` instead of the working directory.
+func WithEnvDir(dir string) EnvOption {
+	return func(o *envOptions) {
+		o.dir = dir
+	}
+}
+
+// Load environment variables of the profile selected by [EnvProfile], and return the profile.
+// It panics if the dotenv files cannot be loaded, see [LoadEnvProfile].
+func LoadEnv() string {
+	profile, err := LoadEnvProfile(EnvProfile())
+	if err != nil {
+		Panic(err)
+	}
+	return profile
+}
+
+// Load environment variables of a profile from .env files, and return the profile.
+// If the profile is empty, it is selected by [EnvProfile].
 // If the environment variable is already set, it WILL NOT be overwritten.
 // The following table shows the priority of environment variables:
 // 1. `.env.development.local`
@@ -39,73 +88,330 @@ var (
 // 7. `.env.production`
 // 8. `.env`
 // View more: https://github.com/bkeepers/dotenv#what-other-env-files-can-i-use
-func LoadEnv() string {
-	if env == nil || *env == "" {
-		*env = "development"
+//
+// After all files are merged, `${VAR}`, `${VAR:-default}` and `${VAR:?error}` references are expanded,
+// so a file can reference a value defined in a lower-priority file.
+// An error is returned if a file cannot be parsed or a reference cannot be resolved.
+func LoadEnvProfile(profile string, opts ...EnvOption) (string, error) {
+	if profile == "" {
+		profile = EnvProfile()
+	}
+
+	var o envOptions
+	for _, opt := range opts {
+		opt(&o)
 	}
-	godotenv.Load(".env." + *env + ".local")
-	if *env != "test" {
-		godotenv.Load(".env.local")
+
+	files := envFiles(profile)
+	for i, filename := range files {
+		files[i] = filepath.Join(o.dir, filename)
+	}
+	if err := loadEnvFiles(files, false); err != nil {
+		return profile, err
 	}
-	godotenv.Load(".env." + *env)
-	godotenv.Load() // Load the default environment
-	return *env
+	if o.watch > 0 {
+		watchEnvFiles(files, o.watch)
+	}
+	return profile, nil
+}
+
+// The dotenv files of a profile, from the highest to the lowest priority.
+func envFiles(profile string) []string {
+	files := []string{".env." + profile + ".local"}
+	if profile != "test" {
+		files = append(files, ".env.local")
+	}
+	return append(files, ".env."+profile, ".env")
+}
+
+// The variables loaded from dotenv files. They are replaced at once on reload,
+// so concurrent readers never see a half-applied set of variables.
+type envState struct {
+	vars    map[string]string // Current values loaded from dotenv files
+	managed map[string]bool   // Every key ever loaded from dotenv files, including the removed ones
+}
+
+var (
+	loadedEnv    atomic.Pointer[envState]
+	loadedEnvMu  sync.Mutex  // Serializes loading and reloading
+	envMirroring atomic.Bool // The process environment is being updated to the loaded snapshot
+)
+
+// Merge the dotenv files, expand their variables, then apply them.
+// A variable defined in a file overrides the same one in the files behind it,
+// and none of them overrides a variable already set in the process environment.
+// If `notify` is true, the subscribers of [OnEnvChange] are notified if any variable is changed.
+func loadEnvFiles(files []string, notify bool) error {
+	prev, next, changed, err := applyEnvFiles(files)
+	if err != nil {
+		return err
+	}
+	if notify {
+		notifyEnvChange(changed, prev.vars, next.vars)
+	}
+	return nil
+}
+
+func applyEnvFiles(files []string) (prev, next *envState, changed []string, err error) {
+	loadedEnvMu.Lock()
+	defer loadedEnvMu.Unlock()
+
+	prev = loadedEnv.Load()
+	if prev == nil {
+		prev = &envState{vars: map[string]string{}, managed: map[string]bool{}}
+	}
+	// A loaded variable whose process value differs from the snapshot was set by the application after loading,
+	// e.g. by os.Setenv or t.Setenv, so it is not managed by the dotenv files anymore
+	overridden := func(key string) bool {
+		osVal, osOk := os.LookupEnv(key)
+		val, ok := prev.vars[key]
+		return prev.managed[key] && (osOk != ok || osVal != val)
+	}
+	// Look up the process environment only, the values loaded before must not shadow the files
+	processEnv := func(key string) (string, bool) {
+		if prev.managed[key] && !overridden(key) {
+			return "", false
+		}
+		return os.LookupEnv(key)
+	}
+
+	merged := make(map[string]dotenvValue)
+	sources := make(map[string]string)
+	for _, filename := range files {
+		vars, err := readDotenv(filename)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for key, val := range vars {
+			if _, ok := merged[key]; !ok {
+				merged[key] = val
+				sources[key] = filepath.Base(filename)
+			}
+		}
+	}
+
+	vars, err := expandDotenv(merged, processEnv)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	next = &envState{vars: make(map[string]string), managed: make(map[string]bool)}
+	for key := range prev.managed {
+		// An overridden key is not in the snapshot, it must be read from the process environment
+		if !overridden(key) {
+			next.managed[key] = true
+		}
+	}
+	for key, val := range vars {
+		if _, ok := processEnv(key); ok {
+			setEnvSource(key, envSource{source: EnvSourceProcess}, false)
+			continue
+		}
+		next.vars[key] = val
+		next.managed[key] = true
+		setEnvSource(key, envSource{source: sources[key]}, true)
+	}
+	// Mirror the changes to the process environment for the libraries reading it directly.
+	// The snapshot is read instead of the process environment while it is being updated.
+	envMirroring.Store(true)
+	defer envMirroring.Store(false)
+	loadedEnv.Store(next)
+	changed = diffEnv(prev.vars, next.vars)
+	for _, key := range changed {
+		if val, ok := next.vars[key]; ok {
+			os.Setenv(key, val)
+		} else if !overridden(key) {
+			os.Unsetenv(key)
+		}
+	}
+	return prev, next, changed, nil
+}
+
+// Get the keys which are added, removed or changed from `old` to `new`.
+func diffEnv(old, new map[string]string) (changed []string) {
+	for key, val := range new {
+		if oldVal, ok := old[key]; !ok || oldVal != val {
+			changed = append(changed, key)
+		}
+	}
+	for key := range old {
+		if _, ok := new[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
+
+// The types supported by [Env], [EnvE] and [MustEnv].
+type EnvType interface {
+	string | bool | int | int64 | uint | uint64 | float64 |
+		time.Duration | time.Time | *url.URL |
+		[]string | []int | map[string]string
 }
 
 // Get environment variable. If the environment variable is not set, return the default value.
 // T is the type of the environment variable value, and the default value must be the same type.
-// T can be string, int, bool, time.Duration, []string or []int
-func Env[T string | bool | int | time.Duration | []string | []int](key string, fallback T) T {
-	value, ok := os.LookupEnv(strings.ToUpper(key))
-	if !ok {
+// T can be string, bool, int, int64, uint, uint64, float64, time.Duration, time.Time, *url.URL,
+// []string, []int or map[string]string. Slices are comma-separated (`a,b,c`), maps are comma-separated pairs (`k1=v1,k2=v2`).
+// The default value is also returned when the value cannot be parsed, use [EnvE] to get the parse error.
+func Env[T EnvType](key string, fallback T) T {
+	val, err := EnvE[T](key)
+	if err != nil {
+		if errors.Is(err, ErrEnvNotSet) {
+			setEnvSource(strings.ToUpper(key), envSource{source: EnvSourceDefault, fallback: fallback}, false)
+		}
 		return fallback
 	}
+	return val
+}
 
-	typeof := reflect.TypeOf(fallback)
-	switch typeof.Kind() {
-	case reflect.String:
-		return reflect.ValueOf(value).Convert(typeof).Interface().(T)
-	case reflect.Bool:
-		val, err := strconv.ParseBool(value)
-		if err != nil {
-			return fallback
+// Get environment variable, and return an [*EnvError] if it is not set or cannot be parsed as T.
+// If KEY is not set, the content of the file at KEY_FILE is used, and values prefixed with `enc:` are decrypted by [Decrypt].
+// A missing variable can be told apart from a malformed one by errors.Is(err, [ErrEnvNotSet]).
+func EnvE[T EnvType](key string) (T, error) {
+	var t T
+	value, raw, ok, err := lookupEnvValue(key)
+	if !ok {
+		return t, &EnvError{Key: strings.ToUpper(key), Err: ErrEnvNotSet}
+	}
+	if err != nil {
+		return t, &EnvError{Key: strings.ToUpper(key), Value: raw, Err: err}
+	}
+
+	val, err := parseEnv[T](value)
+	if err != nil {
+		return t, &EnvError{Key: strings.ToUpper(key), Value: raw, Err: hideEnvSecret(err, value, raw)}
+	}
+	return val, nil
+}
+
+// Get environment variable like [EnvE], but panic if it is not set or cannot be parsed as T.
+// It is intended for variables which the application cannot start without.
+func MustEnv[T EnvType](key string) T {
+	val, err := EnvE[T](key)
+	if err != nil {
+		Panic(err)
+	}
+	return val
+}
+
+// ErrEnvNotSet is wrapped by [EnvError] when the environment variable is not set.
+var ErrEnvNotSet = errors.New("variable is not set")
+
+// EnvError describes an environment variable which is missing or cannot be parsed.
+type EnvError struct {
+	Key   string // Name of the environment variable
+	Value string // Raw value of the environment variable, empty if it is not set
+	Err   error  // ErrEnvNotSet or the parse error
+}
+
+func (e *EnvError) Error() string {
+	if errors.Is(e.Err, ErrEnvNotSet) {
+		return fmt.Sprintf("env %s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("env %s=%q: %v", e.Key, e.Value, e.Err)
+}
+
+func (e *EnvError) Unwrap() error {
+	return e.Err
+}
+
+// Parse the raw value of an environment variable as T.
+func parseEnv[T EnvType](value string) (t T, err error) {
+	val, err := envStrConv(value, reflect.TypeOf(t))
+	if err != nil {
+		return t, err
+	}
+	return val.Interface().(T), nil
+}
+
+// Convert the raw value of an environment variable to `reflectType` by [ReflectStrConv].
+// Slices can also be written as comma-separated values (`a,b,c`),
+// and maps with string keys as comma-separated pairs (`k1=v1,k2=v2`).
+func envStrConv(value string, reflectType reflect.Type) (reflect.Value, error) {
+	switch reflectType.Kind() {
+	case reflect.Slice:
+		if reflectType.Elem().Kind() == reflect.Uint8 || strings.HasPrefix(strings.TrimSpace(value), "[") {
+			break
 		}
-		return reflect.ValueOf(val).Convert(typeof).Interface().(T)
-	case reflect.Int:
-		val, err := strconv.Atoi(value)
-		if err != nil {
-			return fallback
+		slice := reflect.MakeSlice(reflectType, 0, 0)
+		if value == "" {
+			return slice, nil
 		}
-		return reflect.ValueOf(val).Convert(typeof).Interface().(T)
-	case reflect.Slice:
-		switch typeof.Elem().Kind() {
-		case reflect.String:
-			return reflect.ValueOf(strings.Split(value, ",")).Convert(typeof).Interface().(T)
-		case reflect.Int:
-			vals := strings.Split(value, ",")
-			var intVals []int
-			for _, v := range vals {
-				val, err := strconv.Atoi(v)
-				if err != nil {
-					return fallback
-				}
-				intVals = append(intVals, val)
+		for _, v := range strings.Split(value, ",") {
+			elem, err := envStrConv(strings.TrimSpace(v), reflectType.Elem())
+			if err != nil {
+				return slice, err
 			}
-			return reflect.ValueOf(intVals).Convert(typeof).Interface().(T)
+			slice = reflect.Append(slice, elem)
 		}
-		return fallback
-	case reflect.Struct:
-		if typeof == reflect.TypeOf(time.Duration(0)) {
-			val, err := time.ParseDuration(value)
+		return slice, nil
+	case reflect.Map:
+		if reflectType.Key().Kind() != reflect.String || strings.HasPrefix(strings.TrimSpace(value), "{") {
+			break
+		}
+		m := reflect.MakeMap(reflectType)
+		if value == "" {
+			return m, nil
+		}
+		for _, pair := range strings.Split(value, ",") {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				return m, fmt.Errorf("invalid map entry %q, expected key=value", pair)
+			}
+			elem, err := envStrConv(strings.TrimSpace(v), reflectType.Elem())
 			if err != nil {
-				return fallback
+				return m, err
 			}
-			return reflect.ValueOf(val).Convert(typeof).Interface().(T)
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)).Convert(reflectType.Key()), elem)
 		}
-		return fallback
-	default:
-		return fallback
+		return m, nil
+	}
+
+	r, err := ReflectStrConv(value, reflectType)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	rv := reflect.ValueOf(r)
+	switch {
+	case rv.Type() == reflectType:
+		return rv, nil
+	case rv.Kind() == reflect.Pointer && rv.Elem().Type() == reflectType:
+		return rv.Elem(), nil
+	case rv.Kind() != reflect.Slice && rv.Kind() != reflect.Map && rv.CanConvert(reflectType):
+		return rv.Convert(reflectType), nil
+	}
+
+	// JSON arrays and objects are decoded as generic values, decode them again into `reflectType`.
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	dest := reflect.New(reflectType)
+	if err := json.Unmarshal(bytes, dest.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return dest.Elem(), nil
+}
+
+// Look up an environment variable by its upper-cased key in the process environment.
+// While a reload is applied, the variables loaded from dotenv files are read from the new snapshot,
+// so readers never see a half-applied set of variables. Otherwise the process environment wins,
+// including the values set by the application after loading.
+func lookupEnv(key string) (string, bool) {
+	key = strings.ToUpper(key)
+	var value string
+	var ok bool
+	if st := loadedEnv.Load(); st != nil && st.managed[key] && envMirroring.Load() {
+		value, ok = st.vars[key]
+	} else {
+		value, ok = os.LookupEnv(key)
+	}
+	if ok {
+		setEnvSource(key, envSource{source: EnvSourceProcess}, false)
 	}
+	return value, ok
 }
 
 // Get application name, default is "app"
@@ -0,0 +1,126 @@
+package goutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeDotenv(t *testing.T, dir string, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnvSetenvAfterReload(t *testing.T) {
+	dir := t.TempDir()
+	writeDotenv(t, dir, "RELOAD_BASE=hello\nRELOAD_OTHER=1\n")
+	t.Cleanup(func() {
+		os.Unsetenv("RELOAD_BASE")
+		os.Unsetenv("RELOAD_OTHER")
+	})
+
+	if _, err := LoadEnvProfile("test", WithEnvDir(dir)); err != nil {
+		t.Fatal(err)
+	}
+	if got := Env("RELOAD_BASE", ""); got != "hello" {
+		t.Fatalf("RELOAD_BASE = %q, want hello", got)
+	}
+
+	os.Setenv("RELOAD_BASE", "overridden")
+	if got := Env("RELOAD_BASE", ""); got != "overridden" {
+		t.Fatalf("after Setenv, RELOAD_BASE = %q, want overridden", got)
+	}
+
+	writeDotenv(t, dir, "RELOAD_BASE=changed\nRELOAD_OTHER=2\n")
+	if _, err := LoadEnvProfile("test", WithEnvDir(dir)); err != nil {
+		t.Fatal(err)
+	}
+	if got := Env("RELOAD_BASE", ""); got != "overridden" {
+		t.Fatalf("after reload, RELOAD_BASE = %q, want overridden", got)
+	}
+	if got := Env("RELOAD_OTHER", 0); got != 2 {
+		t.Fatalf("after reload, RELOAD_OTHER = %d, want 2", got)
+	}
+
+	writeDotenv(t, dir, "RELOAD_BASE=changed\n")
+	if _, err := LoadEnvProfile("test", WithEnvDir(dir)); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := os.LookupEnv("RELOAD_BASE"); !ok || got != "overridden" {
+		t.Fatalf("after reload, process RELOAD_BASE = %q, want overridden", got)
+	}
+	if _, ok := os.LookupEnv("RELOAD_OTHER"); ok {
+		t.Fatal("RELOAD_OTHER removed from the file is still set")
+	}
+}
+
+func TestEnvUnsetTimeFallback(t *testing.T) {
+	loc := VnLocation
+	VnLocation = nil // LoadLocation isn't called yet
+	t.Cleanup(func() { VnLocation = loc })
+
+	cutoff := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if got := Env("UNSET_CUTOFF", cutoff); !got.Equal(cutoff) {
+		t.Fatalf("Env = %v, want %v", got, cutoff)
+	}
+	if got := envFallbackStr(cutoff); got != "2024-01-02T03:04:05Z" {
+		t.Fatalf("envFallbackStr = %q", got)
+	}
+}
+
+func TestEnvSetenvDuringMirroring(t *testing.T) {
+	dir := t.TempDir()
+	writeDotenv(t, dir, "MIRROR_BASE=hello\n")
+	t.Cleanup(func() { os.Unsetenv("MIRROR_BASE") })
+	if _, err := LoadEnvProfile("test", WithEnvDir(dir)); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("MIRROR_BASE", "overridden")
+	writeDotenv(t, dir, "MIRROR_BASE=changed\n")
+	if _, err := LoadEnvProfile("test", WithEnvDir(dir)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers during the next reload see the snapshot of the managed keys
+	envMirroring.Store(true)
+	defer envMirroring.Store(false)
+	if got := Env("MIRROR_BASE", "fallback"); got != "overridden" {
+		t.Fatalf("while mirroring, MIRROR_BASE = %q, want overridden", got)
+	}
+}
+
+func TestOnEnvChangeOnlyOnWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeDotenv(t, dir, "NOTIFY_BASE=1\n")
+	t.Cleanup(func() {
+		StopEnvWatch()
+		os.Unsetenv("NOTIFY_BASE")
+	})
+
+	changes := make(chan string, 10)
+	OnEnvChange([]string{"NOTIFY_BASE"}, func(old, new map[string]string) {
+		changes <- new["NOTIFY_BASE"]
+	})
+	if _, err := LoadEnvProfile("test", WithEnvDir(dir), WithEnvWatch(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case val := <-changes:
+		t.Fatalf("notified on the first load with %q", val)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	writeDotenv(t, dir, "NOTIFY_BASE=22\n")
+	select {
+	case val := <-changes:
+		if val != "22" {
+			t.Fatalf("notified with %q, want 22", val)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("not notified of the reload")
+	}
+}
@@ -0,0 +1,246 @@
+package goutils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// EnvVar describes an environment variable expected by the application, see [RegisterEnv].
+type EnvVar struct {
+	Name        string
+	Description string
+	Type        reflect.Type // Type of the value, converted the same way as [Env]. Default is string.
+	Required    bool         // The variable must be set, unless Default is given
+	Default     string       // Documented default value, written to .env.example
+	Allowed     []string     // Allowed raw values, any value is allowed if empty
+	Pattern     string       // Regular expression the raw value must match
+	Min         string       // Minimum value of numbers, durations and times, or minimum length of strings, slices and maps
+	Max         string       // Maximum value of numbers, durations and times, or maximum length of strings, slices and maps
+}
+
+var (
+	envSchema   []EnvVar
+	envSchemaMu sync.RWMutex
+)
+
+// Register the environment variables expected by the application.
+// They are validated by [ValidateEnv], which is called by [QuickLoad], and documented by [WriteEnvExample].
+// A variable registered again replaces the previous one.
+func RegisterEnv(vars ...EnvVar) {
+	envSchemaMu.Lock()
+	defer envSchemaMu.Unlock()
+
+	for _, v := range vars {
+		v.Name = strings.ToUpper(v.Name)
+		replaced := false
+		for i := range envSchema {
+			if envSchema[i].Name == v.Name {
+				envSchema[i], replaced = v, true
+				break
+			}
+		}
+		if !replaced {
+			envSchema = append(envSchema, v)
+		}
+	}
+}
+
+// EnvViolation describes a registered environment variable with an invalid value.
+type EnvViolation struct {
+	Name   string
+	Value  string // Raw value, masked if the key looks like a secret
+	Reason string
+}
+
+// EnvValidationError is returned by [ValidateEnv], its message is a table of every violation.
+type EnvValidationError struct {
+	Violations []EnvViolation
+}
+
+func (e *EnvValidationError) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString("┌─────── INVALID ENV: ─────────\r\n")
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "│ NAME\tVALUE\tREASON\r\n")
+	for _, v := range e.Violations {
+		fmt.Fprintf(w, "│ %s\t%s\t%s\r\n", v.Name, v.Value, v.Reason)
+	}
+	w.Flush()
+	buf.WriteString("└──────────────────────────────────────")
+	return buf.String()
+}
+
+// Validate the registered environment variables, and return an [*EnvValidationError] with every violation.
+func ValidateEnv() error {
+	envSchemaMu.RLock()
+	defer envSchemaMu.RUnlock()
+
+	var violations []EnvViolation
+	for _, v := range envSchema {
+		value, raw, ok, err := lookupEnvValue(v.Name)
+		if !ok {
+			if v.Required && v.Default == "" {
+				violations = append(violations, EnvViolation{Name: v.Name, Reason: "required variable is not set"})
+			}
+			continue
+		}
+
+		var reasons []string
+		if err != nil {
+			reasons = []string{err.Error()}
+		} else {
+			reasons = v.validate(value)
+		}
+		for _, reason := range reasons {
+			masked := raw
+			if IsSecretKey(v.Name) || raw != value {
+				// Parse errors quote the value too
+				masked = MaskSecret(raw)
+				reason = strings.ReplaceAll(reason, strconv.Quote(value), strconv.Quote(MaskSecret(value)))
+			}
+			violations = append(violations, EnvViolation{Name: v.Name, Value: masked, Reason: reason})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &EnvValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// Validate a raw value, and return the reasons it is invalid.
+func (v EnvVar) validate(value string) (reasons []string) {
+	if len(v.Allowed) > 0 && !containsAny(v.Allowed, []string{value}) {
+		reasons = append(reasons, fmt.Sprintf("must be one of %s", strings.Join(v.Allowed, "|")))
+	}
+	if v.Pattern != "" {
+		if re, err := regexp.Compile(v.Pattern); err != nil {
+			reasons = append(reasons, fmt.Sprintf("invalid pattern %q: %v", v.Pattern, err))
+		} else if !re.MatchString(value) {
+			reasons = append(reasons, fmt.Sprintf("must match %s", v.Pattern))
+		}
+	}
+
+	typ := v.Type
+	if typ == nil {
+		typ = reflect.TypeOf("")
+	}
+	val, err := envStrConv(value, typ)
+	if err != nil {
+		return append(reasons, fmt.Sprintf("invalid %s: %v", typ, err))
+	}
+
+	for _, bound := range []struct {
+		limit string
+		min   bool
+	}{{v.Min, true}, {v.Max, false}} {
+		if bound.limit == "" {
+			continue
+		}
+		cmp, err := compareEnvValue(val, bound.limit)
+		if err != nil {
+			reasons = append(reasons, err.Error())
+		} else if bound.min && cmp < 0 {
+			reasons = append(reasons, fmt.Sprintf("must be at least %s", bound.limit))
+		} else if !bound.min && cmp > 0 {
+			reasons = append(reasons, fmt.Sprintf("must be at most %s", bound.limit))
+		}
+	}
+	return reasons
+}
+
+// Compare a converted value with a limit, by value for numbers, durations and times, or by length otherwise.
+func compareEnvValue(val reflect.Value, limit string) (int, error) {
+	if val.Type() == reflect.TypeOf(time.Time{}) {
+		l, err := ParseTime(limit)
+		if err != nil {
+			return 0, fmt.Errorf("invalid limit %q: %v", limit, err)
+		}
+		return val.Interface().(time.Time).Compare(l), nil
+	}
+
+	var a, b float64
+	switch val.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		a = float64(val.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		a = float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		a = float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		a = val.Float()
+	default:
+		return 0, fmt.Errorf("min/max is not supported for %s", val.Type())
+	}
+
+	limitType := reflect.TypeOf(float64(0))
+	if val.Type() == reflect.TypeOf(time.Duration(0)) {
+		limitType = val.Type()
+	}
+	l, err := envStrConv(limit, limitType)
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit %q: %v", limit, err)
+	}
+	if limitType == val.Type() {
+		b = float64(l.Int())
+	} else {
+		b = l.Float()
+	}
+
+	switch {
+	case a < b:
+		return -1, nil
+	case a > b:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Write the registered environment variables to a .env.example file,
+// with their description and constraints as comments, and their default values.
+func WriteEnvExample(filename string) error {
+	envSchemaMu.RLock()
+	defer envSchemaMu.RUnlock()
+
+	var buf bytes.Buffer
+	for i, v := range envSchema {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if v.Description != "" {
+			fmt.Fprintf(&buf, "# %s\n", v.Description)
+		}
+
+		typ := "string"
+		if v.Type != nil {
+			typ = v.Type.String()
+		}
+		constraints := []string{typ}
+		if v.Required {
+			constraints = append(constraints, "required")
+		}
+		if len(v.Allowed) > 0 {
+			constraints = append(constraints, "allowed: "+strings.Join(v.Allowed, "|"))
+		}
+		if v.Pattern != "" {
+			constraints = append(constraints, "pattern: "+v.Pattern)
+		}
+		if v.Min != "" {
+			constraints = append(constraints, "min: "+v.Min)
+		}
+		if v.Max != "" {
+			constraints = append(constraints, "max: "+v.Max)
+		}
+		fmt.Fprintf(&buf, "# (%s)\n", strings.Join(constraints, ", "))
+		fmt.Fprintf(&buf, "%s=%s\n", v.Name, v.Default)
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
@@ -0,0 +1,76 @@
+package goutils
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvNamespace looks up environment variables of a named connection, see [EnvScope].
+type EnvNamespace struct {
+	parts []string
+}
+
+// Create a namespace of environment variables, e.g. `EnvScope("REDIS", "AGGS")` for the connection named "aggs".
+// Its keys are looked up from the most specific prefix to the least specific one:
+// `EnvScope("REDIS", "AGGS").Get("URL", "")` reads REDIS_AGGS_URL, then REDIS_URL if it is not set.
+func EnvScope(prefix string, name ...string) *EnvNamespace {
+	parts := []string{strings.ToUpper(prefix)}
+	for _, n := range name {
+		if n != "" {
+			parts = append(parts, strings.ToUpper(n))
+		}
+	}
+	return &EnvNamespace{parts: parts}
+}
+
+// Get the name of the environment variable holding `key`, e.g. REDIS_AGGS_URL.
+// If no variable of the namespace is set, the least specific name is returned, e.g. REDIS_URL.
+func (s *EnvNamespace) Key(key string) string {
+	key = strings.ToUpper(key)
+	for i := len(s.parts); i > 1; i-- {
+		name := strings.Join(s.parts[:i], "_") + "_" + key
+		// Only the presence is checked, the value is neither read from its file nor decrypted
+		if _, ok := lookupEnv(name); ok {
+			return name
+		}
+		if _, ok := lookupEnv(name + "_FILE"); ok {
+			return name
+		}
+	}
+	return s.parts[0] + "_" + key
+}
+
+// Get a string variable of the namespace. If it is not set, return the default value.
+func (s *EnvNamespace) Get(key string, fallback string) string {
+	return Env(s.Key(key), fallback)
+}
+
+// Get a variable of the namespace like [Env]. If it is not set, return the default value.
+func ScopedEnv[T EnvType](s *EnvNamespace, key string, fallback T) T {
+	return Env(s.Key(key), fallback)
+}
+
+// List the connection names defining `key` under the namespace, in lower case.
+// For example, `EnvScope("REDIS").Names("URL")` returns ["aggs", "cache"] for REDIS_AGGS_URL and REDIS_CACHE_URL.
+// The default connection (REDIS_URL) is not listed.
+func (s *EnvNamespace) Names(key string) []string {
+	prefix := strings.Join(s.parts, "_") + "_"
+	suffix := "_" + strings.ToUpper(key)
+
+	found := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		name = strings.TrimSuffix(name, "_FILE")
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, suffix) && len(name) > len(prefix)+len(suffix) {
+			found[strings.ToLower(name[len(prefix):len(name)-len(suffix)])] = true
+		}
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
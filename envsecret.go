@@ -0,0 +1,55 @@
+package goutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefix of encrypted values in environment variables, e.g. `DB_PASSWORD=enc:2Z8aX...`.
+const EnvEncryptedPrefix = "enc:"
+
+// Look up an environment variable and resolve its secret value:
+//   - If KEY is not set but KEY_FILE is, the content of that file is the value (e.g. Kubernetes secrets mounted as files).
+//   - If the value starts with [EnvEncryptedPrefix], it is decrypted by [Decrypt].
+//
+// The returned raw value is the one to report in errors, it is never the decrypted secret:
+// the path of the file for a value read from KEY_FILE, the encrypted value otherwise.
+func lookupEnvValue(key string) (value string, raw string, ok bool, err error) {
+	key = strings.ToUpper(key)
+	value, ok = lookupEnv(key)
+	raw = value
+	if !ok {
+		path, hasFile := lookupEnv(key + "_FILE")
+		if !hasFile {
+			return "", "", false, nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", path, true, fmt.Errorf("read %s_FILE: %v", key, err)
+		}
+		setEnvSource(key, envSource{source: path}, true)
+		value = strings.TrimRight(string(content), "\r\n")
+		raw = path // The content is a secret, it must not end up in errors
+	}
+
+	// The crypt keys are read by Decrypt itself, so they cannot be encrypted
+	if !strings.HasPrefix(value, EnvEncryptedPrefix) || strings.HasPrefix(key, "SECRET_CRYPT_") {
+		return value, raw, true, nil
+	}
+	plain, err := Decrypt(strings.TrimPrefix(value, EnvEncryptedPrefix))
+	if err != nil {
+		return "", raw, true, fmt.Errorf("decrypt: %v", err)
+	}
+	return plain, raw, true, nil
+}
+
+// Hide a secret value in a parse error, e.g. `strconv.Atoi: parsing "<secret>": invalid syntax`.
+// The value is a secret if it differs from its raw value: read from a file or decrypted.
+func hideEnvSecret(err error, value string, raw string) error {
+	if err == nil || value == raw || value == "" || !strings.Contains(err.Error(), value) {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), value, "******"))
+}
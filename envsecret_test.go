@@ -0,0 +1,27 @@
+package goutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvFileErrorHidesSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "port")
+	if err := os.WriteFile(path, []byte("s3cret-not-a-number\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRET_FILE_PORT_FILE", path)
+
+	_, err := EnvE[int]("SECRET_FILE_PORT")
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if strings.Contains(err.Error(), "s3cret") {
+		t.Fatalf("error leaks the file content: %v", err)
+	}
+	if !strings.Contains(err.Error(), path) {
+		t.Fatalf("error doesn't name the file: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package goutils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The sources reported by [EnvSources], besides the dotenv file names.
+const (
+	EnvSourceProcess = "process env" // The variable was set before the dotenv files were loaded
+	EnvSourceDefault = "default"     // The variable is not set, the fallback value of [Env] was used
+)
+
+type envSource struct {
+	source   string
+	fallback interface{} // The fallback value, if the source is EnvSourceDefault. It is formatted when printed only
+}
+
+var (
+	envSources   = make(map[string]envSource)
+	envSourcesMu sync.RWMutex
+)
+
+// Record the source of a variable. It doesn't override a known source unless `override` is true.
+func setEnvSource(key string, src envSource, override bool) {
+	envSourcesMu.RLock()
+	_, ok := envSources[key]
+	envSourcesMu.RUnlock()
+	if ok && !override {
+		return
+	}
+
+	envSourcesMu.Lock()
+	defer envSourcesMu.Unlock()
+	if _, ok := envSources[key]; !ok || override {
+		envSources[key] = src
+	}
+}
+
+// Get the origin of every environment variable loaded from dotenv files or read by [Env].
+// The origin is the dotenv file name (e.g. `.env.development.local`, `.env`), [EnvSourceProcess] or [EnvSourceDefault].
+func EnvSources() map[string]string {
+	envSourcesMu.RLock()
+	defer envSourcesMu.RUnlock()
+
+	sources := make(map[string]string, len(envSources))
+	for key, src := range envSources {
+		sources[key] = src.source
+	}
+	return sources
+}
+
+// Print the value and origin of every variable reported by [EnvSources].
+// Values of secret-looking keys (e.g. `*_SECRET`, `*_PASSWORD`, `*_TOKEN`) are masked by [RedactField].
+func PrintEnvSources() {
+	envSourcesMu.RLock()
+	keys := make([]string, 0, len(envSources))
+	for key := range envSources {
+		keys = append(keys, key)
+	}
+	envSourcesMu.RUnlock()
+	sort.Strings(keys)
+
+	fmt.Printf("\r\n┌─────── ENV SOURCES: ─────────\r\n")
+	for _, key := range keys {
+		envSourcesMu.RLock()
+		src := envSources[key]
+		envSourcesMu.RUnlock()
+
+		value, ok := lookupEnv(key)
+		if !ok {
+			value = envFallbackStr(src.fallback)
+		}
+		fmt.Printf("│ %s=%v (%s)\r\n", key, RedactField(key, value), src.source)
+	}
+	fmt.Println("└──────────────────────────────────────")
+}
+
+// Format a fallback value of [Env]. A time is formatted without [TimeStr], which needs [LoadLocation].
+func envFallbackStr(fallback interface{}) string {
+	if t, ok := fallback.(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	return ToStr(fallback)
+}
+
+var secretKeyRegex = regexp.MustCompile(`(?i)(SECRET|PASSWORD|PASSWD|PWD|TOKEN|PRIVATE|CREDENTIAL|API_?KEY|(^|_)KEY$|(^|_)SEED$)`)
+
+// Check a key name looks like it holds a secret, e.g. `API_CLIENT_SECRET`, `DB_PASSWORD`, `SECRET_CRYPT_SEED`.
+func IsSecretKey(key string) bool {
+	return secretKeyRegex.MatchString(key)
+}
+
+// Mask a secret value, only its first and last characters are kept if it is long enough.
+func MaskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	r := []rune(s)
+	if len(r) < 12 {
+		return "******"
+	}
+	return string(r[:2]) + "******" + string(r[len(r)-2:])
+}
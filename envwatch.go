@@ -0,0 +1,156 @@
+package goutils
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watch the dotenv files of the profile, and reload them when any of them is changed, created or removed.
+// The files are checked every `interval`. Use [OnEnvChange] to be notified of the changed variables.
+func WithEnvWatch(interval time.Duration) EnvOption {
+	return func(o *envOptions) {
+		o.watch = interval
+	}
+}
+
+var (
+	envWatchStop chan struct{}
+	envWatchMu   sync.Mutex
+)
+
+// Stop watching the dotenv files started by [WithEnvWatch].
+func StopEnvWatch() {
+	envWatchMu.Lock()
+	defer envWatchMu.Unlock()
+	if envWatchStop != nil {
+		close(envWatchStop)
+		envWatchStop = nil
+	}
+}
+
+type envFileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func statEnvFiles(files []string) []envFileStamp {
+	stamps := make([]envFileStamp, len(files))
+	for i, filename := range files {
+		if info, err := os.Stat(filename); err == nil {
+			stamps[i] = envFileStamp{modTime: info.ModTime(), size: info.Size(), exists: true}
+		}
+	}
+	return stamps
+}
+
+// Poll the dotenv files and reload them on change. A previous watcher is stopped.
+func watchEnvFiles(files []string, interval time.Duration) {
+	StopEnvWatch()
+
+	envWatchMu.Lock()
+	stop := make(chan struct{})
+	envWatchStop = stop
+	envWatchMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		stamps := statEnvFiles(files)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			current := statEnvFiles(files)
+			if equalEnvFileStamps(stamps, current) {
+				continue
+			}
+			stamps = current
+			if err := loadEnvFiles(files, true); err != nil {
+				Errorf("failed to reload env files, keep the current variables: %v", err)
+			}
+		}
+	}()
+}
+
+func equalEnvFileStamps(a, b []envFileStamp) bool {
+	for i := range a {
+		if a[i].exists != b[i].exists || a[i].size != b[i].size || !a[i].modTime.Equal(b[i].modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+type envSubscriber struct {
+	keys []string
+	fn   func(old, new map[string]string)
+}
+
+var (
+	envSubscribers   []envSubscriber
+	envSubscribersMu sync.RWMutex
+)
+
+// Subscribe to changes of the variables loaded from dotenv files, when they are reloaded by [WithEnvWatch].
+// It is not called by [LoadEnvProfile], including the first load.
+// `fn` is called once per reload if any of `keys` is added, removed or changed,
+// with the old and new values of all `keys` (a removed variable is absent from the map).
+// If `keys` is empty, `fn` is called with the changed variables only.
+func OnEnvChange(keys []string, fn func(old, new map[string]string)) {
+	upperKeys := make([]string, len(keys))
+	for i, key := range keys {
+		upperKeys[i] = strings.ToUpper(key)
+	}
+
+	envSubscribersMu.Lock()
+	defer envSubscribersMu.Unlock()
+	envSubscribers = append(envSubscribers, envSubscriber{keys: upperKeys, fn: fn})
+}
+
+func notifyEnvChange(changed []string, oldVars, newVars map[string]string) {
+	if len(changed) == 0 {
+		return
+	}
+
+	envSubscribersMu.RLock()
+	subscribers := envSubscribers
+	envSubscribersMu.RUnlock()
+
+	for _, sub := range subscribers {
+		keys := sub.keys
+		if len(keys) == 0 {
+			keys = changed
+		} else if !containsAny(changed, keys) {
+			continue
+		}
+
+		old, new := make(map[string]string), make(map[string]string)
+		for _, key := range keys {
+			if val, ok := oldVars[key]; ok {
+				old[key] = val
+			}
+			if val, ok := newVars[key]; ok {
+				new[key] = val
+			}
+		}
+		sub.fn(old, new)
+	}
+}
+
+func containsAny(s []string, values []string) bool {
+	for _, a := range s {
+		for _, b := range values {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,11 +1,11 @@
 module github.com/hecigo/goutils
 
-go 1.20
+go 1.21
 
 require (
 	github.com/goccy/go-json v0.10.2
-	github.com/joho/godotenv v1.5.1
 	github.com/sirupsen/logrus v1.9.1
+	golang.org/x/crypto v0.29.0
 )
 
-require golang.org/x/sys v0.8.0 // indirect
+require golang.org/x/sys v0.27.0 // indirect
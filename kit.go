@@ -1,8 +1,27 @@
 package goutils
 
+import (
+	"errors"
+	"fmt"
+)
+
+// Load environment variables, enable the logger and the default timezone, then return the environment profile.
+// It panics if a variable registered by [RegisterEnv] is invalid, after printing a table of every violation,
+// or if the encryption keys are misconfigured (see [ValidateCryptKeys]).
 func QuickLoad() string {
 	env := LoadEnv()
 	EnableLogrus()
+	if err := ValidateEnv(); err != nil {
+		var verr *EnvValidationError
+		if !errors.As(err, &verr) {
+			Panic(err)
+		}
+		fmt.Println(verr)
+		Panicf("%d invalid environment variables", len(verr.Violations))
+	}
+	if err := ValidateCryptKeys(); err != nil {
+		Panic(err)
+	}
 	LoadLocation()
 	return env
 }
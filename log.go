@@ -1,7 +1,10 @@
 package goutils
 
 import (
+	"log/slog"
+	"runtime"
 	"strings"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -10,19 +13,26 @@ import (
 // It needs to be initialized before use in the main function.
 // Environment variables can be used to configure the logger:
 //   - LOG_AS_JSON=true|false (default: false) - log as JSON
+//   - LOG_JSON_SCHEMA=default|ecs|gcp (default: default) - the JSON keys, ecs and gcp imply LOG_AS_JSON, see [LogSchemaECS] and [LogSchemaGCP]
 //   - LOG_LEVEL=trace|debug|info|warn|error|fatal|panic (default: warn)
+//   - LOG_LEVEL_<NAME>=trace|...|panic - level of the logger named by [GetLogger], e.g. LOG_LEVEL_REDIS=debug
 //   - LOG_METHOD_NAME=true|false (default: true) - log the calling method name
+//   - LOG_CALLER_FULL_PATH=true|false (default: false) - log the absolute file path of the caller, instead of the path relative to the working directory
+//   - LOG_OUTPUT, LOG_STDERR_LEVEL, LOG_FILE*, LOG_SYSLOG* - output sinks, see [logSinkHooks]
+//   - LOG_REDACT=true|false (default: true) - mask sensitive data in messages and fields, see [Redact]
+//   - LOG_REDACT_RULES, LOG_REDACT_KEYS, LOG_REDACT_PATTERNS - redaction rules, see [newLogRedactor]
+//   - LOG_SAMPLING_* - limit the repeated Warn and Error entries, see [newLogSampler]
+//   - LOG_SLOG_DEFAULT=true|false (default: false) - set the slog default logger to write to logrus, see [NewSlogHandler]
+//
+// The caller is the application code, goutils and the packages registered by [SkipCallerPackages] are skipped.
+// Use [WithFields], [WithError] or [WithContext] to log structured entries.
 func EnableLogrus() {
-	// Log as JSON instead of the default ASCII formatter.
-	if Env("LOG_AS_JSON", false) {
-		log.SetFormatter(&log.JSONFormatter{})
-	} else {
-		log.SetFormatter(&log.TextFormatter{
-			ForceColors:   true,
-			DisableColors: false,
-			FullTimestamp: true,
-		})
-	}
+	callerFullPath = Env("LOG_CALLER_FULL_PATH", false)
+	callerWorkDir = getwd()
+
+	log.SetFormatter(newLogFormatter(true))
+	sinkFormatter = newLogFormatter(false)
+
 	switch strings.ToLower(Env("LOG_LEVEL", "warn")) {
 	case "trace":
 		log.SetLevel(log.TraceLevel)
@@ -41,84 +51,134 @@ func EnableLogrus() {
 	}
 
 	log.SetReportCaller(Env("LOG_METHOD_NAME", true))
+	callerHookOnce.Do(func() {
+		log.AddHook(callerHook{}) // Report the caller of goutils wrappers instead of the wrappers themselves
+	})
+
+	r, err := newLogRedactor()
+	if err != nil {
+		Errorf("failed to enable log redaction: %v", err)
+	}
+	redactor.Store(r)
+
+	hooks, err := logSinkHooks()
+	if err != nil {
+		Errorf("failed to enable log sinks: %v", err)
+	}
+	if Env("LOG_REDACT", true) {
+		hooks = append([]log.Hook{redactHook{}}, hooks...) // Redact before writing to the sinks
+	}
+	setLogHooks(hooks)
+	syncNamedLoggers(true)
+	setLogSampler(newLogSampler())
+
+	if Env("LOG_SLOG_DEFAULT", false) {
+		slog.SetDefault(slog.New(NewSlogHandler()))
+	}
+}
+
+var callerHookOnce sync.Once
+
+// Create the formatter configured by LOG_AS_JSON and LOG_JSON_SCHEMA. Colors are only used by the text formatter.
+func newLogFormatter(colors bool) log.Formatter {
+	switch schema := strings.ToLower(Env("LOG_JSON_SCHEMA", "default")); schema {
+	case LogSchemaECS, LogSchemaGCP:
+		return newSchemaFormatter(schema)
+	}
+
+	// Log as JSON instead of the default ASCII formatter.
+	if Env("LOG_AS_JSON", false) {
+		return &log.JSONFormatter{
+			CallerPrettyfier: prettyCaller,
+		}
+	}
+	return &log.TextFormatter{
+		ForceColors:   colors,
+		DisableColors: !colors,
+		FullTimestamp: true,
+		CallerPrettyfier: func(frame *runtime.Frame) (string, string) {
+			function, file := prettyCaller(frame)
+			return function + "()", file
+		},
+	}
 }
 
-// Trace logs a message at level Trace on the standard logger.
+// Trace logs a message at level Trace on the current backend, see [LogBackend].
 func Trace(args ...interface{}) {
-	log.Trace(args...)
+	logArgs(log.TraceLevel, args...)
 }
 
-// Debug logs a message at level Debug on the standard logger.
+// Debug logs a message at level Debug on the current backend, see [LogBackend].
 func Debug(args ...interface{}) {
-	log.Debug(args...)
+	logArgs(log.DebugLevel, args...)
 }
 
-// Print logs a message at level Info on the standard logger.
+// Print logs a message at level Info on the current backend, see [LogBackend].
 func Print(args ...interface{}) {
-	log.Print(args...)
+	logArgs(log.InfoLevel, args...)
 }
 
-// Info logs a message at level Info on the standard logger.
+// Info logs a message at level Info on the current backend, see [LogBackend].
 func Info(args ...interface{}) {
-	log.Info(args...)
+	logArgs(log.InfoLevel, args...)
 }
 
-// Warn logs a message at level Warn on the standard logger.
+// Warn logs a message at level Warn on the current backend, see [LogBackend].
 func Warn(args ...interface{}) {
-	log.Warn(args...)
+	logArgs(log.WarnLevel, args...)
 }
 
-// Error logs a message at level Error on the standard logger.
+// Error logs a message at level Error on the current backend, see [LogBackend].
 func Error(args ...interface{}) {
-	log.Error(args...)
+	logArgs(log.ErrorLevel, args...)
 }
 
-// Panic logs a message at level Panic on the standard logger.
+// Panic logs a message at level Panic on the current backend, see [LogBackend].
 func Panic(args ...interface{}) {
-	log.Panic(args...)
+	logArgs(log.PanicLevel, args...)
 }
 
-// Fatal logs a message at level Fatal on the standard logger then the process will exit with status set to 1.
+// Fatal logs a message at level Fatal on the current backend then the process will exit with status set to 1.
 func Fatal(args ...interface{}) {
-	log.Fatal(args...)
+	logArgs(log.FatalLevel, args...)
 }
 
-// Tracef logs a message at level Trace on the standard logger.
+// Tracef logs a message at level Trace on the current backend, see [LogBackend].
 func Tracef(format string, args ...interface{}) {
-	log.Tracef(format, args...)
+	logFormat(log.TraceLevel, format, args...)
 }
 
-// Debugf logs a message at level Debug on the standard logger.
+// Debugf logs a message at level Debug on the current backend, see [LogBackend].
 func Debugf(format string, args ...interface{}) {
-	log.Debugf(format, args...)
+	logFormat(log.DebugLevel, format, args...)
 }
 
-// Printf logs a message at level Info on the standard logger.
+// Printf logs a message at level Info on the current backend, see [LogBackend].
 func Printf(format string, args ...interface{}) {
-	log.Printf(format, args...)
+	logFormat(log.InfoLevel, format, args...)
 }
 
-// Infof logs a message at level Info on the standard logger.
+// Infof logs a message at level Info on the current backend, see [LogBackend].
 func Infof(format string, args ...interface{}) {
-	log.Infof(format, args...)
+	logFormat(log.InfoLevel, format, args...)
 }
 
-// Warnf logs a message at level Warn on the standard logger.
+// Warnf logs a message at level Warn on the current backend, see [LogBackend].
 func Warnf(format string, args ...interface{}) {
-	log.Warnf(format, args...)
+	logFormat(log.WarnLevel, format, args...)
 }
 
-// Errorf logs a message at level Error on the standard logger.
+// Errorf logs a message at level Error on the current backend, see [LogBackend].
 func Errorf(format string, args ...interface{}) {
-	log.Errorf(format, args...)
+	logFormat(log.ErrorLevel, format, args...)
 }
 
-// Panicf logs a message at level Panic on the standard logger.
+// Panicf logs a message at level Panic on the current backend, see [LogBackend].
 func Panicf(format string, args ...interface{}) {
-	log.Panicf(format, args...)
+	logFormat(log.PanicLevel, format, args...)
 }
 
-// Fatalf logs a message at level Fatal on the standard logger then the process will exit with status set to 1.
+// Fatalf logs a message at level Fatal on the current backend then the process will exit with status set to 1.
 func Fatalf(format string, args ...interface{}) {
-	log.Fatalf(format, args...)
+	logFormat(log.FatalLevel, format, args...)
 }
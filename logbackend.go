@@ -0,0 +1,301 @@
+package goutils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LogBackend writes the entries of the package-level log functions, from [Trace] to [Fatalf], and of the [Logger]s.
+// The default backend is the logrus standard logger configured by [EnableLogrus], use [SetLogBackend] to replace it.
+//
+// A backend may panic at level Panic, otherwise the log function panics after logging.
+// At level Fatal, the log function exits the process after logging.
+type LogBackend interface {
+	Enabled(level log.Level) bool
+	Log(level log.Level, msg string)
+}
+
+// LogFieldsBackend is a [LogBackend] writing the fields of the [Logger] entries as structured data, e.g. slog attributes.
+// The fields are appended to the message as `key=value` on the other backends.
+type LogFieldsBackend interface {
+	LogBackend
+	LogFields(ctx context.Context, level log.Level, msg string, fields map[string]interface{})
+}
+
+type logBackendHolder struct{ LogBackend }
+
+var logBackend atomic.Pointer[logBackendHolder]
+
+// Replace the backend of the package-level log functions and the [Logger]s, nil restores the logrus standard logger.
+//
+//	goutils.SetLogBackend(goutils.NewSlogBackend(slog.Default()))
+func SetLogBackend(b LogBackend) {
+	if b == nil {
+		logBackend.Store(nil)
+		return
+	}
+	logBackend.Store(&logBackendHolder{b})
+}
+
+func currentLogBackend() LogBackend {
+	if h := logBackend.Load(); h != nil {
+		return h.LogBackend
+	}
+	return logrusBackend{log.StandardLogger()}
+}
+
+// Log a message built by fmt.Sprint on the current backend.
+func logArgs(level log.Level, args ...interface{}) {
+	backendArgs(currentLogBackend(), level, args...)
+}
+
+// Log a message built by fmt.Sprintf on the current backend.
+func logFormat(level log.Level, format string, args ...interface{}) {
+	backendFormat(currentLogBackend(), level, format, args...)
+}
+
+// Log a message built by fmt.Sprint on a backend.
+func backendArgs(b LogBackend, level log.Level, args ...interface{}) {
+	if b.Enabled(level) || level <= log.FatalLevel {
+		msg := fmt.Sprint(args...)
+		logMessage(b, level, msg, msg)
+	}
+}
+
+// Log a message built by fmt.Sprintf on a backend.
+func backendFormat(b LogBackend, level log.Level, format string, args ...interface{}) {
+	if b.Enabled(level) || level <= log.FatalLevel {
+		logMessage(b, level, fmt.Sprintf(format, args...), format)
+	}
+}
+
+// Log a message unless it is dropped by sampling, which counts it by `key`.
+// Then panic or exit at level Panic or Fatal, like logrus does.
+func logMessage(b LogBackend, level log.Level, msg string, key string) {
+	if b.Enabled(level) && sampleMessage(level, key) {
+		b.Log(level, msg)
+	}
+	switch level {
+	case log.PanicLevel:
+		panic(msg)
+	case log.FatalLevel:
+		log.Exit(1)
+	}
+}
+
+// The backend of the entries of a Logger: its fields and context are added to the entries,
+// and the level of a named logger (see [GetLogger]) applies too.
+type entryBackend struct {
+	backend LogBackend
+	entry   *log.Entry
+}
+
+func (b entryBackend) Enabled(level log.Level) bool {
+	if b.entry.Logger != log.StandardLogger() && !b.entry.Logger.IsLevelEnabled(level) {
+		return false
+	}
+	return b.backend.Enabled(level)
+}
+
+func (b entryBackend) Log(level log.Level, msg string) {
+	if fb, ok := b.backend.(LogFieldsBackend); ok {
+		fb.LogFields(b.entry.Context, level, msg, b.entry.Data)
+		return
+	}
+
+	keys := make([]string, 0, len(b.entry.Data))
+	for k := range b.entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, " %s=%v", k, b.entry.Data[k])
+	}
+	b.backend.Log(level, sb.String())
+}
+
+// Create a backend writing to a logrus logger, e.g. a logger not configured by [EnableLogrus].
+func NewLogrusBackend(logger *log.Logger) LogBackend {
+	return logrusBackend{logger}
+}
+
+type logrusBackend struct {
+	logger *log.Logger
+}
+
+func (b logrusBackend) Enabled(level log.Level) bool {
+	return b.logger.IsLevelEnabled(level)
+}
+
+func (b logrusBackend) Log(level log.Level, msg string) {
+	b.logger.Log(level, msg)
+}
+
+func (b logrusBackend) LogFields(ctx context.Context, level log.Level, msg string, fields map[string]interface{}) {
+	b.logger.WithContext(ctx).WithFields(fields).Log(level, msg)
+}
+
+// Create a backend writing to a slog logger. The source of the records is the code calling the log functions.
+// Levels are mapped to slog levels: Trace is LevelDebug-4, Fatal is LevelError+4 and Panic is LevelError+8.
+func NewSlogBackend(logger *slog.Logger) LogBackend {
+	return slogBackend{logger}
+}
+
+type slogBackend struct {
+	logger *slog.Logger
+}
+
+func (b slogBackend) Enabled(level log.Level) bool {
+	return b.logger.Enabled(context.Background(), slogLevel(level))
+}
+
+func (b slogBackend) Log(level log.Level, msg string) {
+	b.LogFields(nil, level, msg, nil)
+}
+
+// Log a message with the fields as attributes, sorted by key.
+func (b slogBackend) LogFields(ctx context.Context, level log.Level, msg string, fields map[string]interface{}) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	r := slog.NewRecord(time.Now(), slogLevel(level), msg, callerPC())
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.AddAttrs(slog.Any(k, fields[k]))
+	}
+	b.logger.Handler().Handle(ctx, r)
+}
+
+// Get the program counter of the first caller which is not a log wrapper, see [isLogWrapper].
+func callerPC() uintptr {
+	var pcs [1]uintptr
+	for skip := 2; skip < 32; skip++ {
+		if runtime.Callers(skip, pcs[:]) == 0 {
+			return 0
+		}
+		frame, _ := runtime.CallersFrames(pcs[:]).Next()
+		if !isLogWrapper(frame.Function) {
+			return pcs[0]
+		}
+	}
+	return 0
+}
+
+// Map a logrus level to a slog level.
+func slogLevel(level log.Level) slog.Level {
+	switch level {
+	case log.TraceLevel:
+		return slog.LevelDebug - 4
+	case log.DebugLevel:
+		return slog.LevelDebug
+	case log.InfoLevel:
+		return slog.LevelInfo
+	case log.WarnLevel:
+		return slog.LevelWarn
+	case log.ErrorLevel:
+		return slog.LevelError
+	case log.FatalLevel:
+		return slog.LevelError + 4
+	default:
+		return slog.LevelError + 8
+	}
+}
+
+// Map a slog level to a logrus level, levels above LevelError are mapped to Error so they don't panic or exit.
+func logrusLevel(level slog.Level) log.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return log.TraceLevel
+	case level < slog.LevelInfo:
+		return log.DebugLevel
+	case level < slog.LevelWarn:
+		return log.InfoLevel
+	case level < slog.LevelError:
+		return log.WarnLevel
+	default:
+		return log.ErrorLevel
+	}
+}
+
+// Create a slog.Handler writing to the logrus standard logger, so the records of libraries using slog
+// have the same format, hooks and sinks as the goutils log functions. Attributes are logged as fields,
+// the attributes of a group are prefixed by its name, e.g. `http.method`.
+// It is set as the slog default logger by [EnableLogrus] if LOG_SLOG_DEFAULT is true.
+func NewSlogHandler() slog.Handler {
+	return &slogHandler{logger: log.StandardLogger()}
+}
+
+type slogHandler struct {
+	logger *log.Logger
+	fields log.Fields
+	prefix string // The groups opened by WithGroup, e.g. "http."
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.IsLevelEnabled(logrusLevel(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make(log.Fields, len(h.fields)+r.NumAttrs())
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addSlogAttr(fields, h.prefix, a)
+		return true
+	})
+
+	entry := log.NewEntry(h.logger).WithContext(ctx).WithFields(fields).WithTime(r.Time)
+	entry.Log(logrusLevel(r.Level), r.Message)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(log.Fields, len(h.fields)+len(attrs))
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	for _, a := range attrs {
+		addSlogAttr(fields, h.prefix, a)
+	}
+	return &slogHandler{logger: h.logger, fields: fields, prefix: h.prefix}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, fields: h.fields, prefix: h.prefix + name + "."}
+}
+
+// Add an attribute to fields, the attributes of a group are flattened.
+func addSlogAttr(fields log.Fields, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addSlogAttr(fields, prefix, ga)
+		}
+		return
+	}
+	fields[prefix+a.Key] = a.Value.Any()
+}
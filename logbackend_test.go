@@ -0,0 +1,50 @@
+package goutils
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestLoggerFollowsBackend(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogBackend(NewSlogBackend(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	t.Cleanup(func() { SetLogBackend(nil) })
+	GetLogger("backend-test")
+	if err := SetLogLevel("backend-test", "warn"); err != nil {
+		t.Fatal(err)
+	}
+
+	WithField("order_id", 42).Info("paid")
+	GetLogger("backend-test").Info("dropped by the level of the named logger")
+	GetLogger("backend-test").WithField("attempt", 3).Warnf("retry %s", "redis")
+
+	out := buf.String()
+	for _, want := range []string{"msg=paid order_id=42", `msg="retry redis" attempt=3 logger=backend-test`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "dropped by the level") {
+		t.Errorf("the level of the named logger is ignored:\n%s", out)
+	}
+}
+
+type plainBackend struct{ msgs []string }
+
+func (b *plainBackend) Enabled(level log.Level) bool    { return true }
+func (b *plainBackend) Log(level log.Level, msg string) { b.msgs = append(b.msgs, msg) }
+
+func TestLoggerFieldsAppendedToMessage(t *testing.T) {
+	b := &plainBackend{}
+	SetLogBackend(b)
+	t.Cleanup(func() { SetLogBackend(nil) })
+
+	WithFields(map[string]interface{}{"b": 2, "a": "x"}).Errorf("failed %d", 1)
+	if len(b.msgs) != 1 || b.msgs[0] != "failed 1 a=x b=2" {
+		t.Fatalf("messages = %q", b.msgs)
+	}
+}
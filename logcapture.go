@@ -0,0 +1,114 @@
+package goutils
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CapturedLog is a log entry recorded by [CaptureLogs].
+type CapturedLog struct {
+	Time     time.Time
+	Level    log.Level
+	Message  string
+	Fields   map[string]interface{}
+	Function string // The calling function, e.g. `orders.(*Service).Pay`
+	Caller   string // The calling file and line, e.g. `orders/service.go:42`
+}
+
+// LogCapture records the entries of the standard logger, see [CaptureLogs].
+type LogCapture struct {
+	mu      sync.Mutex
+	entries []CapturedLog
+}
+
+// The part of testing.TB used by [CaptureLogs], so goutils doesn't import the testing package.
+type TestingT interface {
+	Helper()
+	Cleanup(func())
+}
+
+// Record the entries of the standard logger until the end of a test, instead of writing them to the output.
+// Every level is recorded, the previous configuration is restored by t.Cleanup.
+// The named loggers of [GetLogger] are recorded too at every level, the log backend is reset to logrus (see [SetLogBackend])
+// and the sampling of LOG_SAMPLING_INITIAL is disabled.
+//
+//	logs := goutils.CaptureLogs(t)
+//	svc.Pay(order)
+//	if entries := logs.Entries(); len(entries) != 1 || entries[0].Level != logrus.WarnLevel { ... }
+func CaptureLogs(t TestingT) *LogCapture {
+	t.Helper()
+	c := &LogCapture{}
+	std := log.StandardLogger()
+
+	level, out, reportCaller, backend := std.GetLevel(), std.Out, std.ReportCaller, logBackend.Load()
+	std.SetLevel(log.TraceLevel)
+	std.SetOutput(io.Discard)
+	std.SetReportCaller(true)
+	logBackend.Store(nil)
+	saved := sampler.Swap(nil)
+	hook := &captureHook{c}
+	std.AddHook(hook)
+	logCapturing.Add(1)
+	syncNamedLoggers(false)
+
+	t.Cleanup(func() {
+		removeHooks(hook)
+		std.SetLevel(level)
+		std.SetOutput(out)
+		std.SetReportCaller(reportCaller)
+		logBackend.Store(backend)
+		if s := sampler.Swap(saved); s != nil {
+			close(s.stop) // Set by EnableLogrus during the test
+		}
+		logCapturing.Add(-1)
+		syncNamedLoggers(false)
+	})
+	return c
+}
+
+// Get the entries recorded so far.
+func (c *LogCapture) Entries() []CapturedLog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CapturedLog{}, c.entries...)
+}
+
+// Remove the entries recorded so far.
+func (c *LogCapture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+type captureHook struct {
+	capture *LogCapture
+}
+
+func (h *captureHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *captureHook) Fire(entry *log.Entry) error {
+	callerHook{}.Fire(entry) // In case EnableLogrus isn't called by the test
+
+	captured := CapturedLog{
+		Time:    entry.Time,
+		Level:   entry.Level,
+		Message: entry.Message,
+		Fields:  make(map[string]interface{}, len(entry.Data)),
+	}
+	for k, v := range entry.Data {
+		captured.Fields[k] = v
+	}
+	if entry.HasCaller() {
+		captured.Function, captured.Caller = prettyCaller(entry.Caller)
+	}
+
+	h.capture.mu.Lock()
+	defer h.capture.mu.Unlock()
+	h.capture.entries = append(h.capture.entries, captured)
+	return nil
+}
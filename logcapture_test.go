@@ -0,0 +1,35 @@
+package goutils
+
+import (
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestCaptureLogsSamplingAndNamedLevels(t *testing.T) {
+	s := &logSampler{initial: 1, thereafter: 0, tick: 1 << 62, counters: make(map[sampleKey]int), dropped: make(map[sampleKey]uint64), stop: make(chan struct{})}
+	setLogSampler(s)
+	t.Cleanup(func() { setLogSampler(nil) })
+	GetLogger("capture-test")
+	if err := SetLogLevel("capture-test", "error"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("capture", func(t *testing.T) {
+		logs := CaptureLogs(t)
+		for i := 0; i < 3; i++ {
+			Warn("sampled warning")
+		}
+		GetLogger("capture-test").Debug("named debug")
+		if entries := logs.Entries(); len(entries) != 4 {
+			t.Fatalf("captured %d entries, want 4: %v", len(entries), entries)
+		}
+	})
+
+	if sampler.Load() != s {
+		t.Fatal("the sampler is not restored")
+	}
+	if level := getNamedLogger("capture-test").logrus.GetLevel(); level != log.ErrorLevel {
+		t.Fatalf("named logger level = %s, want error", level)
+	}
+}
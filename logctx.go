@@ -0,0 +1,57 @@
+package goutils
+
+import (
+	"context"
+	"regexp"
+)
+
+// Keys of the logger and the W3C trace context in a Context, like [CtxKey_ConnName].
+// For example:
+//
+//	func Handle(ctx context.Context, req *http.Request) {
+//		ctx = context.WithValue(ctx, goutils.CtxKey_TraceParent, req.Header.Get("traceparent"))
+//		ctx = goutils.ContextWithLogger(ctx, map[string]interface{}{"request_id": req.Header.Get("X-Request-ID")})
+//		goutils.Ctx(ctx).Info("handling request") // logs request_id, trace_id and span_id
+//	}
+const (
+	CtxKey_Logger      ctxKeyType_Log = "logger"
+	CtxKey_TraceParent ctxKeyType_Log = "traceparent"
+)
+
+type ctxKeyType_Log string
+
+// Return a copy of the Context carrying a Logger with `fields`, added to the fields of the Logger already in the Context.
+// If `fields` or the Context has a W3C `traceparent`, its trace ID and parent ID are added as `trace_id` and `span_id`.
+func ContextWithLogger(ctx context.Context, fields map[string]interface{}) context.Context {
+	logger := Ctx(ctx).WithFields(fields)
+	if tp, ok := fields["traceparent"].(string); ok {
+		logger = logger.WithFields(traceParentFields(tp))
+	}
+	return context.WithValue(ctx, CtxKey_Logger, logger)
+}
+
+// Get the Logger carried by the Context, see [ContextWithLogger].
+// If there is none, a Logger of the standard logger is returned, with the trace fields of the Context if any.
+func Ctx(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(CtxKey_Logger).(*Logger); ok {
+		return logger.WithContext(ctx)
+	}
+
+	logger := WithContext(ctx)
+	if tp, ok := ctx.Value(CtxKey_TraceParent).(string); ok {
+		logger = logger.WithFields(traceParentFields(tp))
+	}
+	return logger
+}
+
+// version-traceid-parentid-flags, view more: https://www.w3.org/TR/trace-context/#traceparent-header
+var traceParentRegex = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// Parse a W3C `traceparent` value into log fields. An invalid value results in no field.
+func traceParentFields(traceParent string) map[string]interface{} {
+	m := traceParentRegex.FindStringSubmatch(traceParent)
+	if m == nil || m[1] == "ff" || m[2] == "00000000000000000000000000000000" || m[3] == "0000000000000000" {
+		return nil
+	}
+	return map[string]interface{}{"trace_id": m[2], "span_id": m[3]}
+}
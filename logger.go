@@ -0,0 +1,325 @@
+package goutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Logger is a structured logger, every entry it logs carries its fields.
+// It writes to the logrus standard logger configured by [EnableLogrus], or to the backend set by [SetLogBackend].
+//
+//	goutils.WithFields(map[string]interface{}{"order_id": id}).WithError(err).Error("failed to pay")
+type Logger struct {
+	entry *log.Entry
+}
+
+// Create a Logger with fields from the standard logger.
+func WithFields(fields map[string]interface{}) *Logger {
+	return &Logger{entry: log.WithFields(fields)}
+}
+
+// Create a Logger with a single field from the standard logger.
+func WithField(key string, value interface{}) *Logger {
+	return &Logger{entry: log.WithField(key, value)}
+}
+
+// Create a Logger with an error as the `error` field from the standard logger.
+func WithError(err error) *Logger {
+	return &Logger{entry: log.WithError(err)}
+}
+
+// Create a Logger with a context from the standard logger. The context is passed to the hooks.
+func WithContext(ctx context.Context) *Logger {
+	return &Logger{entry: log.WithContext(ctx)}
+}
+
+// Add fields to a copy of the Logger.
+func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
+	return &Logger{entry: l.entry.WithFields(fields)}
+}
+
+// Add a single field to a copy of the Logger.
+func (l *Logger) WithField(key string, value interface{}) *Logger {
+	return &Logger{entry: l.entry.WithField(key, value)}
+}
+
+// Add an error as the `error` field to a copy of the Logger.
+func (l *Logger) WithError(err error) *Logger {
+	return &Logger{entry: l.entry.WithError(err)}
+}
+
+// Add a context to a copy of the Logger.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	return &Logger{entry: l.entry.WithContext(ctx)}
+}
+
+// Get the fields of the Logger.
+func (l *Logger) Fields() map[string]interface{} {
+	fields := make(map[string]interface{}, len(l.entry.Data))
+	for k, v := range l.entry.Data {
+		fields[k] = v
+	}
+	return fields
+}
+
+// Get the backend of the entries, nil if they are written to logrus, see [SetLogBackend].
+func (l *Logger) backend() LogBackend {
+	if h := logBackend.Load(); h != nil {
+		return entryBackend{backend: h.LogBackend, entry: l.entry}
+	}
+	return nil
+}
+
+// Trace logs a message at level Trace.
+func (l *Logger) Trace(args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendArgs(b, log.TraceLevel, args...)
+	} else {
+		l.entry.Trace(args...)
+	}
+}
+
+// Debug logs a message at level Debug.
+func (l *Logger) Debug(args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendArgs(b, log.DebugLevel, args...)
+	} else {
+		l.entry.Debug(args...)
+	}
+}
+
+// Print logs a message at level Info.
+func (l *Logger) Print(args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendArgs(b, log.InfoLevel, args...)
+	} else {
+		l.entry.Print(args...)
+	}
+}
+
+// Info logs a message at level Info.
+func (l *Logger) Info(args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendArgs(b, log.InfoLevel, args...)
+	} else {
+		l.entry.Info(args...)
+	}
+}
+
+// Warn logs a message at level Warn.
+func (l *Logger) Warn(args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendArgs(b, log.WarnLevel, args...)
+	} else if sampleLog(l.entry.Logger, log.WarnLevel, "", args...) {
+		l.entry.Warn(args...)
+	}
+}
+
+// Error logs a message at level Error.
+func (l *Logger) Error(args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendArgs(b, log.ErrorLevel, args...)
+	} else if sampleLog(l.entry.Logger, log.ErrorLevel, "", args...) {
+		l.entry.Error(args...)
+	}
+}
+
+// Panic logs a message at level Panic, then panics.
+func (l *Logger) Panic(args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendArgs(b, log.PanicLevel, args...)
+	} else {
+		l.entry.Panic(args...)
+	}
+}
+
+// Fatal logs a message at level Fatal then the process will exit with status set to 1.
+func (l *Logger) Fatal(args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendArgs(b, log.FatalLevel, args...)
+	} else {
+		l.entry.Fatal(args...)
+	}
+}
+
+// Tracef logs a message at level Trace.
+func (l *Logger) Tracef(format string, args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendFormat(b, log.TraceLevel, format, args...)
+	} else {
+		l.entry.Tracef(format, args...)
+	}
+}
+
+// Debugf logs a message at level Debug.
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendFormat(b, log.DebugLevel, format, args...)
+	} else {
+		l.entry.Debugf(format, args...)
+	}
+}
+
+// Printf logs a message at level Info.
+func (l *Logger) Printf(format string, args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendFormat(b, log.InfoLevel, format, args...)
+	} else {
+		l.entry.Printf(format, args...)
+	}
+}
+
+// Infof logs a message at level Info.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendFormat(b, log.InfoLevel, format, args...)
+	} else {
+		l.entry.Infof(format, args...)
+	}
+}
+
+// Warnf logs a message at level Warn.
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendFormat(b, log.WarnLevel, format, args...)
+	} else if sampleLog(l.entry.Logger, log.WarnLevel, format) {
+		l.entry.Warnf(format, args...)
+	}
+}
+
+// Errorf logs a message at level Error.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendFormat(b, log.ErrorLevel, format, args...)
+	} else if sampleLog(l.entry.Logger, log.ErrorLevel, format) {
+		l.entry.Errorf(format, args...)
+	}
+}
+
+// Panicf logs a message at level Panic, then panics.
+func (l *Logger) Panicf(format string, args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendFormat(b, log.PanicLevel, format, args...)
+	} else {
+		l.entry.Panicf(format, args...)
+	}
+}
+
+// Fatalf logs a message at level Fatal then the process will exit with status set to 1.
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	if b := l.backend(); b != nil {
+		backendFormat(b, log.FatalLevel, format, args...)
+	} else {
+		l.entry.Fatalf(format, args...)
+	}
+}
+
+// The package name of goutils, e.g. "github.com/hecigo/goutils".
+var goutilsPackage = funcPackage(runtime.FuncForPC(reflect.ValueOf(Info).Pointer()).Name())
+
+// Get the package name of a function name, e.g. "github.com/hecigo/goutils" of "github.com/hecigo/goutils.(*Logger).Info".
+func funcPackage(funcName string) string {
+	lastSlash := strings.LastIndex(funcName, "/")
+	if dot := strings.Index(funcName[lastSlash+1:], "."); dot >= 0 {
+		return funcName[:lastSlash+1+dot]
+	}
+	return funcName
+}
+
+var (
+	callerSkipPackages   []string
+	callerSkipPackagesMu sync.RWMutex
+	callerFullPath       bool      // Set by EnableLogrus from LOG_CALLER_FULL_PATH
+	callerWorkDir        = getwd() // The base of the relative caller paths, set again by EnableLogrus
+)
+
+// Skip the functions of packages when reporting the caller of a log entry, like goutils itself.
+// It is intended for packages wrapping goutils, e.g. an internal logging helper,
+// so the caller is the code calling the wrapper. Package names are full import paths, e.g. "github.com/acme/kit/logx".
+func SkipCallerPackages(pkgs ...string) {
+	callerSkipPackagesMu.Lock()
+	defer callerSkipPackagesMu.Unlock()
+	callerSkipPackages = append(callerSkipPackages, pkgs...)
+}
+
+// callerHook replaces the caller found by logrus, which is a goutils wrapper (e.g. goutils.Info),
+// by the application code calling the wrapper.
+type callerHook struct{}
+
+func (callerHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (callerHook) Fire(entry *log.Entry) error {
+	if entry.Caller == nil || !isLogWrapper(entry.Caller.Function) {
+		return nil
+	}
+
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if funcPackage(frame.Function) == "runtime" {
+			return nil // Logged by goutils itself, e.g. a background goroutine
+		}
+		if !isLogWrapper(frame.Function) {
+			entry.Caller = &frame
+			return nil
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// Check a function belongs to logrus, slog, goutils or a package registered by SkipCallerPackages.
+func isLogWrapper(funcName string) bool {
+	pkg := funcPackage(funcName)
+	switch pkg {
+	case goutilsPackage, "github.com/sirupsen/logrus", "log/slog", "log":
+		return true
+	}
+
+	callerSkipPackagesMu.RLock()
+	defer callerSkipPackagesMu.RUnlock()
+	for _, p := range callerSkipPackages {
+		if pkg == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Format the caller of a log entry as `main.(*Server).Serve` and `cmd/server/main.go:42`.
+// The file path is relative to the working directory, unless LOG_CALLER_FULL_PATH is true.
+func prettyCaller(frame *runtime.Frame) (function string, file string) {
+	function = frame.Function
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		function = function[i+1:]
+	}
+	return function, fmt.Sprintf("%s:%d", callerFile(frame), frame.Line)
+}
+
+// Get the file path of a caller, relative to the working directory unless LOG_CALLER_FULL_PATH is true.
+func callerFile(frame *runtime.Frame) string {
+	if !callerFullPath && callerWorkDir != "" {
+		if rel, err := filepath.Rel(callerWorkDir, frame.File); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return frame.File
+}
+
+// Get the working directory, empty if it is unknown.
+func getwd() string {
+	wd, _ := os.Getwd()
+	return wd
+}
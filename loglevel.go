@@ -0,0 +1,198 @@
+package goutils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/goccy/go-json"
+	log "github.com/sirupsen/logrus"
+)
+
+// A named logger has its own level, the rest of its configuration is shared with the standard logger.
+type namedLogger struct {
+	name    string
+	logrus  *log.Logger
+	mu      sync.Mutex // Guards inherit, level and the level changes
+	inherit bool       // Follow the level of the standard logger
+	level   log.Level  // Its own level, if it doesn't inherit
+}
+
+var (
+	namedLoggers   = make(map[string]*namedLogger)
+	namedLoggersMu sync.RWMutex
+	logCapturing   atomic.Int32 // Number of active [CaptureLogs], the named loggers are at level Trace while it is positive
+)
+
+// Get the Logger of a subsystem, e.g. `GetLogger("redis")`. Its entries have the field `logger` set to the name.
+// Its level is read from LOG_LEVEL_<NAME> (e.g. LOG_LEVEL_REDIS=debug), and follows LOG_LEVEL if it's not set.
+// The output, format and hooks are the same as the standard logger configured by [EnableLogrus].
+// Use [SetLogLevel] or [LogLevelHandler] to change its level at runtime.
+func GetLogger(name string) *Logger {
+	nl := getNamedLogger(name)
+	return &Logger{entry: log.NewEntry(nl.logrus).WithField("logger", nl.name)}
+}
+
+func getNamedLogger(name string) *namedLogger {
+	name = strings.ToLower(name)
+	namedLoggersMu.RLock()
+	nl, ok := namedLoggers[name]
+	namedLoggersMu.RUnlock()
+	if ok {
+		return nl
+	}
+
+	namedLoggersMu.Lock()
+	defer namedLoggersMu.Unlock()
+	if nl, ok := namedLoggers[name]; ok {
+		return nl
+	}
+	nl = &namedLogger{name: name, logrus: log.New(), inherit: true}
+	nl.sync(true)
+	namedLoggers[name] = nl
+	return nl
+}
+
+var nonAlnumRegex = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// Copy the configuration of the standard logger. If `readEnv` is true, the level is read from LOG_LEVEL_<NAME> again.
+func (nl *namedLogger) sync(readEnv bool) {
+	nl.mu.Lock()
+	defer nl.mu.Unlock()
+
+	std := log.StandardLogger()
+	nl.logrus.SetOutput(std.Out)
+	nl.logrus.SetFormatter(std.Formatter)
+	nl.logrus.SetReportCaller(std.ReportCaller)
+	hooks := make(log.LevelHooks, len(std.Hooks))
+	for level, levelHooks := range std.Hooks {
+		hooks[level] = append([]log.Hook{}, levelHooks...)
+	}
+	nl.logrus.ReplaceHooks(hooks)
+
+	if readEnv {
+		key := "LOG_LEVEL_" + nonAlnumRegex.ReplaceAllString(strings.ToUpper(nl.name), "_")
+		if level := Env(key, ""); level != "" {
+			if lvl, err := log.ParseLevel(level); err != nil {
+				Warnf("%s: %v", key, err)
+			} else {
+				nl.level = lvl
+				nl.inherit = false
+			}
+		}
+	}
+	nl.applyLevel()
+}
+
+// Set the level of the logrus logger, nl.mu must be locked.
+func (nl *namedLogger) applyLevel() {
+	lvl := nl.level
+	if nl.inherit {
+		lvl = log.GetLevel()
+	}
+	if logCapturing.Load() > 0 {
+		lvl = log.TraceLevel
+	}
+	nl.logrus.SetLevel(lvl)
+}
+
+// Copy the configuration of the standard logger to the named loggers, it is called by [EnableLogrus].
+func syncNamedLoggers(readEnv bool) {
+	namedLoggersMu.RLock()
+	defer namedLoggersMu.RUnlock()
+	for _, nl := range namedLoggers {
+		nl.sync(readEnv)
+	}
+}
+
+// ErrLoggerNotFound is returned by [SetLogLevel] for a name which is not a logger created by [GetLogger].
+var ErrLoggerNotFound = errors.New("logger not found")
+
+// Change the level of a logger at runtime. An empty name is the standard logger, its level is followed by
+// the named loggers whose level is not set. The level "inherit" makes a named logger follow the standard logger again.
+// Only the loggers created by [GetLogger] can be changed, so the callers of [LogLevelHandler] can't create loggers.
+func SetLogLevel(name string, level string) error {
+	if name == "" {
+		lvl, err := log.ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		// The write lock orders the changes of the standard level with their propagation
+		namedLoggersMu.Lock()
+		defer namedLoggersMu.Unlock()
+		log.SetLevel(lvl)
+		for _, nl := range namedLoggers {
+			nl.sync(false)
+		}
+		return nil
+	}
+
+	namedLoggersMu.RLock()
+	nl, ok := namedLoggers[strings.ToLower(name)]
+	namedLoggersMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrLoggerNotFound, name)
+	}
+	nl.mu.Lock()
+	defer nl.mu.Unlock()
+	if strings.EqualFold(level, "inherit") {
+		nl.inherit = true
+		nl.applyLevel()
+		return nil
+	}
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	nl.inherit = false
+	nl.level = lvl
+	nl.applyLevel()
+	return nil
+}
+
+// Get the level of every logger, the standard logger has an empty name.
+func LogLevels() map[string]string {
+	levels := map[string]string{"": log.GetLevel().String()}
+	namedLoggersMu.RLock()
+	defer namedLoggersMu.RUnlock()
+	for name, nl := range namedLoggers {
+		levels[name] = nl.logrus.GetLevel().String()
+	}
+	return levels
+}
+
+// Create an HTTP handler to view and change the log levels at runtime. It responds an [APIRes] with the levels as data.
+//   - GET: list the levels, see [LogLevels]
+//   - PUT or POST with `?logger=redis&level=debug`: change a level, see [SetLogLevel]
+//
+// It should be mounted on an internal route, e.g. `mux.Handle("/debug/log-levels", goutils.LogLevelHandler())`.
+func LogLevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := APIRes{Status: http.StatusOK}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			name, level := r.FormValue("logger"), r.FormValue("level")
+			if err := SetLogLevel(name, level); errors.Is(err, ErrLoggerNotFound) {
+				res = APIRes{Status: http.StatusNotFound, ErrorCode: "LOGGER_NOT_FOUND", Message: err.Error()}
+			} else if err != nil {
+				res = APIRes{Status: http.StatusBadRequest, ErrorCode: "INVALID_LOG_LEVEL", Message: err.Error()}
+			} else {
+				Warnf("log level of %q is changed to %s", name, level)
+			}
+		default:
+			res = APIRes{Status: http.StatusMethodNotAllowed, Message: fmt.Sprintf("method %s is not allowed", r.Method)}
+		}
+		if res.Status == http.StatusOK {
+			res.Data = LogLevels()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(res.Status)
+		json.NewEncoder(w).Encode(res)
+	})
+}
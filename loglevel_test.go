@@ -0,0 +1,71 @@
+package goutils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestSetLogLevelConcurrent(t *testing.T) {
+	level := log.GetLevel()
+	t.Cleanup(func() { log.SetLevel(level) })
+
+	GetLogger("concurrent")
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetLogLevel("concurrent", "debug")
+			SetLogLevel("concurrent", "inherit")
+		}()
+		go func() {
+			defer wg.Done()
+			SetLogLevel("", "info")
+		}()
+	}
+	wg.Wait()
+
+	if err := SetLogLevel("", "error"); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetLogger("concurrent").entry.Logger.GetLevel(); got != log.ErrorLevel {
+		t.Fatalf("inherited level = %v, want error", got)
+	}
+	if err := SetLogLevel("concurrent", "trace"); err != nil {
+		t.Fatal(err)
+	}
+	SetLogLevel("", "warn")
+	if got := LogLevels()["concurrent"]; got != "trace" {
+		t.Fatalf("level = %v, want trace", got)
+	}
+}
+
+func TestLogLevelHandlerUnknownLogger(t *testing.T) {
+	GetLogger("handler-known")
+	handler := LogLevelHandler()
+
+	req := httptest.NewRequest(http.MethodPost, "/?logger=handler-unknown&level=debug", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	if _, ok := LogLevels()["handler-unknown"]; ok {
+		t.Fatal("the handler created a logger")
+	}
+	if err := SetLogLevel("handler-unknown", "debug"); !errors.Is(err, ErrLoggerNotFound) {
+		t.Fatalf("SetLogLevel = %v, want ErrLoggerNotFound", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/?logger=HANDLER-KNOWN&level=debug", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || LogLevels()["handler-known"] != "debug" {
+		t.Fatalf("status = %d, level = %s", rec.Code, LogLevels()["handler-known"])
+	}
+}
@@ -0,0 +1,168 @@
+package goutils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The replacement of sensitive data matched in log messages.
+const Redacted = "[REDACTED]"
+
+// Built-in redaction rules, selected by LOG_REDACT_RULES.
+var redactRules = map[string]*regexp.Regexp{
+	// `password=...`, `token: ...`, `"api_key":"..."`, the key is kept
+	"secret": regexp.MustCompile(`(?i)((?:password|passwd|pwd|secret|token|api_?key|authorization)"?\s*[=:]\s*)("[^"]*"|'[^']*'|[^\s,;&"']+)`),
+	"bearer": regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`),
+	"jwt":    regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	"card":   regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), // Validated by the Luhn algorithm
+	// Vietnamese mobile numbers: 0xxxxxxxxx or +84xxxxxxxxx
+	"phone": regexp.MustCompile(`(?:\+84|\b84|\b0)(?:3|5|7|8|9)\d{8}\b`),
+	// Vietnamese citizen IDs: CCCD (12 digits) and CMND (9 digits)
+	"national_id": regexp.MustCompile(`\b(?:\d{12}|\d{9})\b`),
+}
+
+// The order to apply the rules: tokens before the generic secrets, and longer numbers first so a card number isn't partially matched as an ID.
+var redactRuleOrder = []string{"bearer", "jwt", "secret", "card", "phone", "national_id"}
+
+// The rules enabled if LOG_REDACT_RULES is not set. The phone and national_id rules match any number
+// of their length (e.g. order IDs and amounts), so they must be enabled explicitly.
+var defaultRedactRules = []string{"bearer", "jwt", "secret", "card"}
+
+type logRedactor struct {
+	keys     map[string]bool // Lower-cased field names to redact, in addition to IsSecretKey
+	patterns []*regexp.Regexp
+	luhn     *regexp.Regexp // The card pattern, its matches must pass the Luhn check
+}
+
+var redactor atomic.Pointer[logRedactor]
+
+// Create the redactor configured by environment variables:
+//   - LOG_REDACT_RULES=bearer,jwt,secret,card,phone,national_id (default: bearer,jwt,secret,card) - built-in rules
+//   - LOG_REDACT_KEYS=otp,pin - field names to redact, in addition to the secret-looking ones (see [IsSecretKey])
+//   - LOG_REDACT_PATTERNS=["regex1","regex2"] - regular expressions to redact, as a JSON array or comma-separated
+func newLogRedactor() (*logRedactor, error) {
+	r := &logRedactor{keys: make(map[string]bool)}
+	for _, key := range Env("LOG_REDACT_KEYS", []string{}) {
+		r.keys[strings.ToLower(key)] = true
+	}
+
+	rules := Env("LOG_REDACT_RULES", defaultRedactRules)
+	for _, name := range redactRuleOrder {
+		if !containsAny(rules, []string{name}) {
+			continue
+		}
+		r.patterns = append(r.patterns, redactRules[name])
+		if name == "card" {
+			r.luhn = redactRules[name]
+		}
+	}
+
+	for _, pattern := range Env("LOG_REDACT_PATTERNS", []string{}) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return r, fmt.Errorf("LOG_REDACT_PATTERNS: %v", err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+func currentRedactor() *logRedactor {
+	if r := redactor.Load(); r != nil {
+		return r
+	}
+	r, _ := newLogRedactor()
+	redactor.CompareAndSwap(nil, r)
+	return redactor.Load()
+}
+
+// Mask the sensitive data in a text: secrets, tokens and card numbers, Vietnamese phone numbers and citizen IDs
+// if they are enabled by LOG_REDACT_RULES, and the patterns configured by LOG_REDACT_PATTERNS.
+func Redact(s string) string {
+	return currentRedactor().redact(s)
+}
+
+// Mask the value of a field: the whole value if the key looks like a secret (see [IsSecretKey]) or is listed in LOG_REDACT_KEYS,
+// otherwise the sensitive data in string and error values.
+func RedactField(key string, value interface{}) interface{} {
+	return currentRedactor().redactField(key, value)
+}
+
+func (r *logRedactor) redact(s string) string {
+	for _, re := range r.patterns {
+		switch {
+		case re == redactRules["secret"]:
+			s = re.ReplaceAllString(s, "${1}"+Redacted)
+		case re == r.luhn:
+			s = re.ReplaceAllStringFunc(s, func(m string) string {
+				if luhnValid(m) {
+					return Redacted
+				}
+				return m
+			})
+		default:
+			s = re.ReplaceAllString(s, Redacted)
+		}
+	}
+	return s
+}
+
+func (r *logRedactor) redactField(key string, value interface{}) interface{} {
+	if IsSecretKey(key) || r.keys[strings.ToLower(key)] {
+		return MaskSecret(ToStr(value))
+	}
+	switch v := value.(type) {
+	case string:
+		return r.redact(v)
+	case error:
+		if msg, redacted := v.Error(), r.redact(v.Error()); redacted != msg {
+			return redacted
+		}
+	case fmt.Stringer:
+		if msg, redacted := v.String(), r.redact(v.String()); redacted != msg {
+			return redacted
+		}
+	}
+	return value
+}
+
+// Check a number passes the Luhn checksum, ignoring spaces and dashes.
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// redactHook masks sensitive data in the message and fields of every log entry.
+// It is installed by EnableLogrus before the sinks, unless LOG_REDACT=false.
+type redactHook struct{}
+
+func (redactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (redactHook) Fire(entry *log.Entry) error {
+	r := currentRedactor()
+	entry.Message = r.redact(entry.Message)
+	for key, value := range entry.Data {
+		entry.Data[key] = r.redactField(key, value)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package goutils
+
+import "testing"
+
+func TestRedactDefaultRules(t *testing.T) {
+	r, err := newLogRedactor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"order 123456789 total 150000000 VND", "order 123456789 total 150000000 VND"},
+		{"call 0912345678", "call 0912345678"},
+		{"password=hunter2 ok", "password=" + Redacted + " ok"},
+		{"Authorization: Bearer abc.def", "Authorization: " + Redacted},
+		{"card 4111 1111 1111 1111", "card " + Redacted},
+	}
+	for _, tt := range tests {
+		if got := r.redact(tt.in); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactOptInRules(t *testing.T) {
+	t.Setenv("LOG_REDACT_RULES", "phone,national_id")
+	r, err := newLogRedactor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.redact("call 0912345678, cccd 001099012345"), "call "+Redacted+", cccd "+Redacted; got != want {
+		t.Errorf("redact() = %q, want %q", got, want)
+	}
+}
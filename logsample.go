@@ -0,0 +1,141 @@
+package goutils
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// logSampler limits the entries of the same level and message: the first `initial` ones per tick are logged,
+// then 1 in `thereafter`. The dropped entries are counted and reported periodically.
+type logSampler struct {
+	initial    int
+	thereafter int
+	tick       time.Duration
+
+	mu       sync.Mutex
+	resetAt  time.Time
+	counters map[sampleKey]int    // Entries per message in the current tick
+	dropped  map[sampleKey]uint64 // Dropped entries per message since the last report
+	stop     chan struct{}
+}
+
+type sampleKey struct {
+	level   log.Level
+	message string
+}
+
+var sampler atomic.Pointer[logSampler]
+
+// Create the sampler configured by environment variables, nil if sampling is disabled:
+//   - LOG_SAMPLING_INITIAL=100 (default: 0, disabled) - entries of the same level and message logged per tick
+//   - LOG_SAMPLING_THEREAFTER=100 (default: 100) - then log 1 in this number of entries, 0 drops all of them
+//   - LOG_SAMPLING_TICK=1s (default: 1s) - the period the counters are reset
+//   - LOG_SAMPLING_REPORT=1m (default: 1m) - the period the dropped counts are logged at level Warn
+//
+// It applies to the Warn and Error wrappers, formatted messages are counted by their format, e.g. `Errorf("failed to call %s: %v", ...)`.
+func newLogSampler() *logSampler {
+	initial := Env("LOG_SAMPLING_INITIAL", 0)
+	if initial <= 0 {
+		return nil
+	}
+	return &logSampler{
+		initial:    initial,
+		thereafter: Env("LOG_SAMPLING_THEREAFTER", 100),
+		tick:       Env("LOG_SAMPLING_TICK", time.Second),
+		counters:   make(map[sampleKey]int),
+		dropped:    make(map[sampleKey]uint64),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Replace the sampler, the dropped counts of the previous one are reported.
+func setLogSampler(s *logSampler) {
+	if s != nil {
+		go s.report(Env("LOG_SAMPLING_REPORT", time.Minute))
+	}
+	if old := sampler.Swap(s); old != nil {
+		close(old.stop)
+	}
+}
+
+// Check an entry of a logger should be logged. The message is `format`, or `args` if it isn't formatted.
+func sampleLog(logger *log.Logger, level log.Level, format string, args ...interface{}) bool {
+	if sampler.Load() == nil || !logger.IsLevelEnabled(level) {
+		return true
+	}
+	if format == "" {
+		format = fmt.Sprint(args...)
+	}
+	return sampleMessage(level, format)
+}
+
+// Check an enabled entry should be logged, only Warn and Error entries are sampled.
+func sampleMessage(level log.Level, message string) bool {
+	s := sampler.Load()
+	if s == nil || level < log.ErrorLevel || level > log.WarnLevel {
+		return true
+	}
+	return s.allow(sampleKey{level, message})
+}
+
+func (s *logSampler) allow(key sampleKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := time.Now(); now.After(s.resetAt) {
+		s.counters = make(map[sampleKey]int)
+		s.resetAt = now.Add(s.tick)
+	}
+	s.counters[key]++
+	n := s.counters[key]
+	if n <= s.initial || (s.thereafter > 0 && (n-s.initial)%s.thereafter == 0) {
+		return true
+	}
+	s.dropped[key]++
+	return false
+}
+
+// Log the dropped counts every `interval`, until the sampler is replaced.
+func (s *logSampler) report(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *logSampler) flush() {
+	s.mu.Lock()
+	dropped := s.dropped
+	s.dropped = make(map[sampleKey]uint64)
+	s.mu.Unlock()
+
+	for key, n := range dropped {
+		logger := WithFields(map[string]interface{}{
+			"sampled_level":   key.level.String(),
+			"sampled_message": key.message,
+			"dropped":         n,
+		})
+		// The report itself is not sampled
+		if b := logger.backend(); b != nil {
+			if b.Enabled(log.WarnLevel) {
+				b.Log(log.WarnLevel, "log entries dropped by sampling")
+			}
+		} else {
+			logger.entry.Warn("log entries dropped by sampling")
+		}
+	}
+}
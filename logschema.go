@@ -0,0 +1,127 @@
+package goutils
+
+import (
+	"github.com/goccy/go-json"
+	log "github.com/sirupsen/logrus"
+)
+
+// The JSON log schemas selected by LOG_JSON_SCHEMA, besides the default keys of logrus (`time`, `level`, `msg`).
+// Every schema can be parsed by the `json` stage of Loki.
+const (
+	// Elastic Common Schema: `@timestamp`, `log.level`, `message`, `log.origin.file.line`, `service.name`, `trace.id`...
+	LogSchemaECS = "ecs"
+
+	// Google Cloud Logging: `timestamp`, `severity`, `message`, `logging.googleapis.com/sourceLocation`, `serviceContext`...
+	// The trace is linked to Cloud Trace if GOOGLE_CLOUD_PROJECT is set.
+	LogSchemaGCP = "gcp"
+)
+
+// The ECS version the entries conform to.
+const ecsVersion = "8.11.0"
+
+// schemaFormatter formats entries as JSON with the keys of a schema.
+// The service is read from AppName and AppVersion, the trace from the `trace_id` and `span_id` fields (see [ContextWithLogger]).
+type schemaFormatter struct {
+	schema  string
+	service string
+	version string
+	project string // The Google Cloud project, for LogSchemaGCP
+}
+
+func newSchemaFormatter(schema string) *schemaFormatter {
+	return &schemaFormatter{
+		schema:  schema,
+		service: AppName(),
+		version: AppVersion(),
+		project: Env("GOOGLE_CLOUD_PROJECT", ""),
+	}
+}
+
+func (f *schemaFormatter) Format(entry *log.Entry) ([]byte, error) {
+	data := make(map[string]interface{}, len(entry.Data)+8)
+	for k, v := range entry.Data {
+		if err, ok := v.(error); ok {
+			v = err.Error() // Otherwise errors are marshalled as empty objects
+		}
+		data[k] = v
+	}
+
+	if f.schema == LogSchemaGCP {
+		f.formatGCP(entry, data)
+	} else {
+		f.formatECS(entry, data)
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func (f *schemaFormatter) formatECS(entry *log.Entry, data map[string]interface{}) {
+	logField := map[string]interface{}{"level": entry.Level.String()}
+	if entry.HasCaller() {
+		function, _ := prettyCaller(entry.Caller)
+		logField["origin"] = map[string]interface{}{
+			"function": function,
+			"file":     map[string]interface{}{"name": callerFile(entry.Caller), "line": entry.Caller.Line},
+		}
+	}
+	if msg, ok := data[log.ErrorKey].(string); ok {
+		delete(data, log.ErrorKey)
+		data["error"] = map[string]interface{}{"message": msg}
+	}
+	if traceID, ok := data["trace_id"]; ok {
+		delete(data, "trace_id")
+		data["trace"] = map[string]interface{}{"id": traceID}
+	}
+	if spanID, ok := data["span_id"]; ok {
+		delete(data, "span_id")
+		data["span"] = map[string]interface{}{"id": spanID}
+	}
+
+	data["@timestamp"] = entry.Time.Format("2006-01-02T15:04:05.000Z07:00")
+	data["message"] = entry.Message
+	data["log"] = logField
+	data["service"] = map[string]interface{}{"name": f.service, "version": f.version}
+	data["ecs"] = map[string]interface{}{"version": ecsVersion}
+}
+
+// The Cloud Logging severity of the levels.
+var gcpSeverities = map[log.Level]string{
+	log.TraceLevel: "DEBUG",
+	log.DebugLevel: "DEBUG",
+	log.InfoLevel:  "INFO",
+	log.WarnLevel:  "WARNING",
+	log.ErrorLevel: "ERROR",
+	log.FatalLevel: "CRITICAL",
+	log.PanicLevel: "ALERT",
+}
+
+func (f *schemaFormatter) formatGCP(entry *log.Entry, data map[string]interface{}) {
+	if entry.HasCaller() {
+		function, _ := prettyCaller(entry.Caller)
+		data["logging.googleapis.com/sourceLocation"] = map[string]interface{}{
+			"file":     callerFile(entry.Caller),
+			"line":     ToStr(entry.Caller.Line), // A string in the LogEntrySourceLocation schema
+			"function": function,
+		}
+	}
+	if traceID, ok := data["trace_id"]; ok {
+		delete(data, "trace_id")
+		if f.project != "" {
+			traceID = "projects/" + f.project + "/traces/" + ToStr(traceID)
+		}
+		data["logging.googleapis.com/trace"] = traceID
+	}
+	if spanID, ok := data["span_id"]; ok {
+		delete(data, "span_id")
+		data["logging.googleapis.com/spanId"] = spanID
+	}
+
+	data["timestamp"] = entry.Time.Format("2006-01-02T15:04:05.000000000Z07:00")
+	data["severity"] = gcpSeverities[entry.Level]
+	data["message"] = entry.Message
+	data["serviceContext"] = map[string]interface{}{"service": f.service, "version": f.version}
+}
@@ -0,0 +1,341 @@
+package goutils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Configure the log sinks from environment variables, and return their hooks. It is called by [EnableLogrus]:
+//   - LOG_OUTPUT=stdout|stderr|none (default: stdout) - the main output
+//   - LOG_STDERR_LEVEL=trace|...|panic - also write entries at this level or above to stderr, e.g. `error`
+//   - LOG_FILE=path - also write entries to a file, rotated by [RotatingFile]
+//   - LOG_FILE_LEVEL (default: trace) - minimum level written to the file
+//   - LOG_FILE_MAX_SIZE=100 - rotate the file when it exceeds this size in MB, 0 means no limit
+//   - LOG_FILE_MAX_AGE=24h - rotate the file when it is older than this duration, 0 means no limit
+//   - LOG_FILE_MAX_BACKUPS=7 - number of rotated files to keep, 0 means keeping all of them
+//   - LOG_FILE_COMPRESS=true|false (default: true) - gzip the rotated files
+//   - LOG_SYSLOG=unix:///dev/log|udp://host:514|tcp://host:514 - also write entries to syslog
+//   - LOG_SYSLOG_LEVEL (default: trace) - minimum level written to syslog
+//   - LOG_SYSLOG_TAG (default: APP_NAME) - syslog tag
+//
+// Sinks are written by hooks with the same format as the main output, without colors.
+func logSinkHooks() ([]log.Hook, error) {
+	switch strings.ToLower(Env("LOG_OUTPUT", "stdout")) {
+	case "stderr":
+		log.SetOutput(os.Stderr)
+	case "none":
+		log.SetOutput(io.Discard)
+	default:
+		log.SetOutput(os.Stdout)
+	}
+
+	var hooks []log.Hook
+	if level := Env("LOG_STDERR_LEVEL", ""); level != "" {
+		hook, err := newWriterHook(os.Stderr, level)
+		if err != nil {
+			return hooks, fmt.Errorf("LOG_STDERR_LEVEL: %v", err)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	if filename := Env("LOG_FILE", ""); filename != "" {
+		file := &RotatingFile{
+			Filename:   filename,
+			MaxSize:    int64(Env("LOG_FILE_MAX_SIZE", 100)) * 1024 * 1024,
+			MaxAge:     Env("LOG_FILE_MAX_AGE", time.Duration(0)),
+			MaxBackups: Env("LOG_FILE_MAX_BACKUPS", 7),
+			Compress:   Env("LOG_FILE_COMPRESS", true),
+		}
+		hook, err := newWriterHook(file, Env("LOG_FILE_LEVEL", "trace"))
+		if err != nil {
+			return hooks, fmt.Errorf("LOG_FILE_LEVEL: %v", err)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	if addr := Env("LOG_SYSLOG", ""); addr != "" {
+		hook, err := newSyslogHook(addr, Env("LOG_SYSLOG_TAG", AppName()), Env("LOG_SYSLOG_LEVEL", "trace"))
+		if err != nil {
+			return hooks, fmt.Errorf("LOG_SYSLOG: %v", err)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+// Write log entries at `level` or above to `w`, in addition to the main output.
+// It's the programmatic equivalent of LOG_FILE and LOG_STDERR_LEVEL, e.g. `AddLogWriter(os.Stderr, "error")`.
+func AddLogWriter(w io.Writer, level string) error {
+	hook, err := newWriterHook(w, level)
+	if err != nil {
+		return err
+	}
+	log.AddHook(hook)
+	return nil
+}
+
+var (
+	logHooks   []log.Hook // Hooks installed by EnableLogrus, replaced when it's called again
+	logHooksMu sync.Mutex
+)
+
+// Replace the hooks installed by EnableLogrus before by `hooks`, and close the replaced ones.
+func setLogHooks(hooks []log.Hook) {
+	logHooksMu.Lock()
+	defer logHooksMu.Unlock()
+
+	removeHooks(logHooks...)
+	for _, hook := range logHooks {
+		if c, ok := hook.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	for _, hook := range hooks {
+		log.AddHook(hook)
+	}
+	logHooks = hooks
+}
+
+// Remove hooks from the standard logger, the other hooks are kept in order.
+func removeHooks(hooks ...log.Hook) {
+	if len(hooks) == 0 {
+		return
+	}
+
+	remaining := make(log.LevelHooks)
+	for level, levelHooks := range log.StandardLogger().Hooks {
+	next:
+		for _, h := range levelHooks {
+			for _, removed := range hooks {
+				if h == removed {
+					continue next
+				}
+			}
+			remaining[level] = append(remaining[level], h)
+		}
+	}
+	log.StandardLogger().ReplaceHooks(remaining)
+}
+
+// Get the levels at `level` or above, e.g. error, fatal and panic for "error".
+func levelsFrom(level string) ([]log.Level, error) {
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	var levels []log.Level
+	for _, l := range log.AllLevels {
+		if l <= lvl {
+			levels = append(levels, l)
+		}
+	}
+	return levels, nil
+}
+
+var sinkFormatter log.Formatter // Set by EnableLogrus, the same format as the main output without colors
+
+// Format an entry for a sink.
+func formatSinkEntry(entry *log.Entry) ([]byte, error) {
+	if sinkFormatter == nil {
+		return entry.Logger.Formatter.Format(entry)
+	}
+	return sinkFormatter.Format(entry)
+}
+
+// writerHook writes log entries to a writer.
+type writerHook struct {
+	mu     sync.Mutex
+	writer io.Writer
+	levels []log.Level
+}
+
+func newWriterHook(w io.Writer, level string) (*writerHook, error) {
+	levels, err := levelsFrom(level)
+	if err != nil {
+		return nil, err
+	}
+	return &writerHook{writer: w, levels: levels}, nil
+}
+
+func (h *writerHook) Levels() []log.Level {
+	return h.levels
+}
+
+func (h *writerHook) Fire(entry *log.Entry) error {
+	bytes, err := formatSinkEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.writer.Write(bytes)
+	return err
+}
+
+// Close the writer if it is closable, except stdout and stderr.
+func (h *writerHook) Close() error {
+	if h.writer == os.Stdout || h.writer == os.Stderr {
+		return nil
+	}
+	if c, ok := h.writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// RotatingFile is an io.WriteCloser writing to a file, which is rotated when it exceeds MaxSize or MaxAge.
+// A rotated file is renamed with its rotation time, e.g. `app-20230102T150405.000.log`, then gzipped if Compress is true.
+type RotatingFile struct {
+	Filename   string        // Path of the file, its directory is created if needed
+	MaxSize    int64         // Maximum size in bytes before rotating, 0 means no limit
+	MaxAge     time.Duration // Maximum age of the file before rotating, 0 means no limit
+	MaxBackups int           // Maximum number of rotated files to keep, 0 means keeping all of them
+	Compress   bool          // Gzip the rotated files
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// Write to the file, rotating it first if needed.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if (f.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.MaxSize) || (f.MaxAge > 0 && time.Since(f.openedAt) >= f.MaxAge) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate the file now.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	return f.rotate()
+}
+
+// Close the file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.Filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.openedAt = file, info.Size(), time.Now()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	ext := filepath.Ext(f.Filename)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(f.Filename, ext), time.Now().Format("20060102T150405.000"), ext)
+	if err := os.Rename(f.Filename, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	go f.cleanup(backup)
+	return nil
+}
+
+// Compress the rotated file, then remove the oldest ones exceeding MaxBackups.
+func (f *RotatingFile) cleanup(backup string) {
+	if f.Compress {
+		if err := gzipFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to compress log file %s: %v\n", backup, err)
+		}
+	}
+	if f.MaxBackups <= 0 {
+		return
+	}
+
+	ext := filepath.Ext(f.Filename)
+	backups, err := filepath.Glob(strings.TrimSuffix(f.Filename, ext) + "-[0-9]*" + ext + "*")
+	if err != nil {
+		return
+	}
+	sort.Strings(backups) // The rotation time makes the names sortable
+	for len(backups) > f.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+func gzipFile(filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(filename+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(filename)
+}
@@ -0,0 +1,23 @@
+//go:build windows || plan9
+
+package goutils
+
+import (
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type syslogHook struct{}
+
+func newSyslogHook(addr string, tag string, level string) (*syslogHook, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+func (h *syslogHook) Levels() []log.Level {
+	return nil
+}
+
+func (h *syslogHook) Fire(entry *log.Entry) error {
+	return nil
+}
@@ -0,0 +1,82 @@
+//go:build !windows && !plan9
+
+package goutils
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// syslogHook writes log entries to syslog, with the syslog severity matching the entry level.
+type syslogHook struct {
+	mu     sync.Mutex
+	writer *syslog.Writer
+	levels []log.Level
+}
+
+// Connect to syslog at `addr`, e.g. `unix:///dev/log`, `udp://localhost:514` or `tcp://localhost:514`.
+func newSyslogHook(addr string, tag string, level string) (*syslogHook, error) {
+	levels, err := levelsFrom(level)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	var w *syslog.Writer
+	switch u.Scheme {
+	case "unix", "unixgram":
+		// The local syslog daemon usually listens on a datagram socket
+		w, err = syslog.Dial("unixgram", u.Path, syslog.LOG_INFO|syslog.LOG_USER, tag)
+		if err != nil {
+			w, err = syslog.Dial("unix", u.Path, syslog.LOG_INFO|syslog.LOG_USER, tag)
+		}
+	case "udp", "tcp":
+		w, err = syslog.Dial(u.Scheme, u.Host, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q, expected unix, udp or tcp", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &syslogHook{writer: w, levels: levels}, nil
+}
+
+func (h *syslogHook) Levels() []log.Level {
+	return h.levels
+}
+
+func (h *syslogHook) Fire(entry *log.Entry) error {
+	bytes, err := formatSinkEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	msg := string(bytes)
+	switch entry.Level {
+	case log.PanicLevel:
+		return h.writer.Emerg(msg)
+	case log.FatalLevel:
+		return h.writer.Crit(msg)
+	case log.ErrorLevel:
+		return h.writer.Err(msg)
+	case log.WarnLevel:
+		return h.writer.Warning(msg)
+	case log.InfoLevel:
+		return h.writer.Info(msg)
+	default:
+		return h.writer.Debug(msg)
+	}
+}
+
+func (h *syslogHook) Close() error {
+	return h.writer.Close()
+}
@@ -0,0 +1,149 @@
+package goutils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The password hashing algorithms selected by PASSWORD_HASH_ALGO.
+const (
+	PasswordArgon2id = "argon2id"
+	PasswordBcrypt   = "bcrypt"
+)
+
+// The limits of the Argon2id parameters, checked before hashing so a bad setting or a forged hash can't exhaust the memory.
+const (
+	argon2MaxMemory  = 1 << 22 // KiB, 4 GiB
+	argon2MaxTime    = 1 << 10
+	argon2MaxThreads = 255
+	argon2MaxKeyLen  = 64
+)
+
+// The parameters of password hashing, read from environment variables.
+type passwordParams struct {
+	algo    string
+	memory  uint32 // Argon2id memory in KiB
+	time    uint32 // Argon2id iterations
+	threads uint8  // Argon2id parallelism
+	cost    int    // bcrypt cost
+}
+
+// Read the parameters of password hashing:
+//   - PASSWORD_HASH_ALGO=argon2id|bcrypt (default: argon2id)
+//   - PASSWORD_ARGON2_MEMORY=65536 - memory in KiB
+//   - PASSWORD_ARGON2_TIME=3 - number of iterations
+//   - PASSWORD_ARGON2_THREADS=4 - degree of parallelism
+//   - PASSWORD_BCRYPT_COST=12
+func passwordHashParams() (passwordParams, error) {
+	p := passwordParams{
+		algo: strings.ToLower(Env("PASSWORD_HASH_ALGO", PasswordArgon2id)),
+		cost: Env("PASSWORD_BCRYPT_COST", 12),
+	}
+	switch p.algo {
+	case PasswordArgon2id:
+		// Check the ranges before the conversions, e.g. -1 must not wrap to 4 TiB of memory
+		memory, time, threads := Env("PASSWORD_ARGON2_MEMORY", 64*1024), Env("PASSWORD_ARGON2_TIME", 3), Env("PASSWORD_ARGON2_THREADS", 4)
+		if memory < 1 || memory > argon2MaxMemory {
+			return p, fmt.Errorf("PASSWORD_ARGON2_MEMORY must be between 1 and %d KiB", argon2MaxMemory)
+		}
+		if time < 1 || time > argon2MaxTime {
+			return p, fmt.Errorf("PASSWORD_ARGON2_TIME must be between 1 and %d", argon2MaxTime)
+		}
+		if threads < 1 || threads > argon2MaxThreads {
+			return p, fmt.Errorf("PASSWORD_ARGON2_THREADS must be between 1 and %d", argon2MaxThreads)
+		}
+		p.memory, p.time, p.threads = uint32(memory), uint32(time), uint8(threads)
+	case PasswordBcrypt:
+		if p.cost < bcrypt.MinCost || p.cost > bcrypt.MaxCost {
+			return p, fmt.Errorf("PASSWORD_BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return p, fmt.Errorf("PASSWORD_HASH_ALGO: unknown algorithm %q, it must be argon2id or bcrypt", p.algo)
+	}
+	return p, nil
+}
+
+// Hash a password to store it, unlike [Encrypt] it can't be reversed. The result is a PHC string with its parameters,
+// e.g. `$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>` or `$2a$12$...` for bcrypt, so they can be upgraded over time.
+// The algorithm and its parameters are read from environment variables, see PASSWORD_HASH_ALGO.
+func HashPassword(password string) (string, error) {
+	p, err := passwordHashParams()
+	if err != nil {
+		return "", err
+	}
+
+	if p.algo == PasswordBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), p.cost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Check a password matches a hash of [HashPassword]. If it matches but the hash doesn't use the current algorithm
+// and parameters, `needsRehash` is true: the password should be hashed again and stored, e.g. after a successful login.
+// An error is returned if the hash is malformed, not if the password doesn't match.
+func VerifyPassword(hash string, password string) (ok bool, needsRehash bool, err error) {
+	p, err := passwordHashParams()
+	if err != nil {
+		return false, false, err
+	}
+
+	if strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$") {
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, false, err
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+		return true, p.algo != PasswordBcrypt || cost != p.cost, nil
+	}
+
+	// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != PasswordArgon2id {
+		return false, false, errors.New("unsupported password hash format")
+	}
+	var version int
+	var h passwordParams
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	var memory, time, threads uint64
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil ||
+		memory < 1 || memory > argon2MaxMemory || time < 1 || time > argon2MaxTime || threads < 1 || threads > argon2MaxThreads {
+		return false, false, fmt.Errorf("invalid argon2 parameters %q", parts[3])
+	}
+	h.memory, h.time, h.threads = uint32(memory), uint32(time), uint8(threads)
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, fmt.Errorf("invalid argon2 salt: %v", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || len(key) > argon2MaxKeyLen {
+		return false, false, errors.New("invalid argon2 hash")
+	}
+
+	other := argon2.IDKey([]byte(password), salt, h.time, h.memory, h.threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+	return true, p.algo != PasswordArgon2id || h.memory != p.memory || h.time != p.time || h.threads != p.threads, nil
+}
@@ -0,0 +1,49 @@
+package goutils
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestPasswordArgon2ParamsRange(t *testing.T) {
+	for _, tc := range []struct{ key, value string }{
+		{"PASSWORD_ARGON2_MEMORY", "-1"},
+		{"PASSWORD_ARGON2_MEMORY", "4194305"},
+		{"PASSWORD_ARGON2_TIME", "0"},
+		{"PASSWORD_ARGON2_THREADS", "257"},
+		{"PASSWORD_ARGON2_THREADS", "-1"},
+	} {
+		t.Run(tc.key+"="+tc.value, func(t *testing.T) {
+			t.Setenv(tc.key, tc.value)
+			if _, err := HashPassword("password"); err == nil {
+				t.Fatalf("expected an error for %s=%s", tc.key, tc.value)
+			}
+		})
+	}
+
+	t.Setenv("PASSWORD_ARGON2_MEMORY", "1024")
+	t.Setenv("PASSWORD_ARGON2_TIME", "1")
+	t.Setenv("PASSWORD_ARGON2_THREADS", "255")
+	hash, err := HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, needsRehash, err := VerifyPassword(hash, "password"); !ok || needsRehash || err != nil {
+		t.Fatalf("VerifyPassword = %v, %v, %v", ok, needsRehash, err)
+	}
+}
+
+func TestVerifyPasswordForgedParams(t *testing.T) {
+	salt, key := "c29tZXNhbHRzb21lc2FsdA", "aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"
+	for _, params := range []string{"m=4294967295,t=1,p=1", "m=4194305,t=1,p=1", "m=1024,t=1025,p=1", "m=1024,t=1,p=256", "m=-1,t=1,p=1", "m=0,t=1,p=1"} {
+		hash := "$argon2id$v=19$" + params + "$" + salt + "$" + key
+		if _, _, err := VerifyPassword(hash, "password"); err == nil {
+			t.Errorf("%s: expected an error", params)
+		}
+	}
+
+	longKey := base64.RawStdEncoding.EncodeToString(make([]byte, argon2MaxKeyLen+1))
+	if _, _, err := VerifyPassword("$argon2id$v=19$m=1024,t=1,p=1$"+salt+"$"+longKey, "password"); err == nil {
+		t.Error("expected an error for a long hash")
+	}
+}
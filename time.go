@@ -50,6 +50,9 @@ func ParseTime(s string) (time.Time, error) {
 	if err != nil {
 		return time.Time{}, err
 	}
+	if VnLocation == nil { // LoadLocation() has not been called yet, e.g. while reading env
+		return t, nil
+	}
 	return t.In(VnLocation), nil
 }
 